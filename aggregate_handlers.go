@@ -0,0 +1,126 @@
+package asynqmon
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for endpoints aggregating multiple clusters
+// ****************************************************************************
+
+// Default name of the cluster RedisConnOpt connects to.
+const defaultClusterName = "default"
+
+type aggregateQueue struct {
+	// Name of the cluster the queue belongs to.
+	Cluster string `json:"cluster"`
+	*queueStateSnapshot
+}
+
+type aggregateTotals struct {
+	Queues      int   `json:"queues"`
+	MemoryUsage int64 `json:"memory_usage_bytes"`
+	Size        int   `json:"size"`
+	Active      int   `json:"active"`
+	Pending     int   `json:"pending"`
+	Aggregating int   `json:"aggregating"`
+	Scheduled   int   `json:"scheduled"`
+	Retry       int   `json:"retry"`
+	Archived    int   `json:"archived"`
+	Completed   int   `json:"completed"`
+	Processed   int   `json:"processed"`
+	Succeeded   int   `json:"succeeded"`
+	Failed      int   `json:"failed"`
+}
+
+func (t *aggregateTotals) add(s *queueStateSnapshot) {
+	t.Queues++
+	t.MemoryUsage += s.MemoryUsage
+	t.Size += s.Size
+	t.Active += s.Active
+	t.Pending += s.Pending
+	t.Aggregating += s.Aggregating
+	t.Scheduled += s.Scheduled
+	t.Retry += s.Retry
+	t.Archived += s.Archived
+	t.Completed += s.Completed
+	t.Processed += s.Processed
+	t.Succeeded += s.Succeeded
+	t.Failed += s.Failed
+}
+
+type clusterError struct {
+	Cluster string `json:"cluster"`
+	Error   string `json:"error"`
+}
+
+type aggregateQueuesResponse struct {
+	// Queues of every cluster which responded, sorted by cluster and queue name.
+	Queues []*aggregateQueue `json:"queues"`
+	// Totals of the queues above; clusters listed in Errors are not included.
+	Totals aggregateTotals `json:"totals"`
+	// Clusters which could not be reached.
+	Errors []*clusterError `json:"errors"`
+}
+
+// newAggregateQueuesHandlerFunc returns a handler which lists the queues of every cluster
+// with grand totals. Clusters are queried concurrently; if some of them fail, the queues of
+// the other clusters are returned along with the errors. The request only fails if every
+// cluster fails.
+func newAggregateQueuesHandlerFunc(clusters map[string]*asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			mu   sync.Mutex
+			wg   sync.WaitGroup
+			resp = aggregateQueuesResponse{
+				Queues: make([]*aggregateQueue, 0),
+				Errors: make([]*clusterError, 0),
+			}
+			lastErr error
+		)
+		for name, inspector := range clusters {
+			wg.Add(1)
+			go func(name string, inspector *asynq.Inspector) {
+				defer wg.Done()
+				snapshots, err := fetchQueueStateSnapshots(traceInspector(r.Context(), inspector))
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					logRequestf(r, "error: could not list queues of cluster %q: %v", name, err)
+					resp.Errors = append(resp.Errors, &clusterError{
+						Cluster: name,
+						Error:   strings.TrimPrefix(err.Error(), "asynq: "),
+					})
+					lastErr = err
+					return
+				}
+				for _, s := range snapshots {
+					resp.Queues = append(resp.Queues, &aggregateQueue{Cluster: name, queueStateSnapshot: s})
+					resp.Totals.add(s)
+				}
+			}(name, inspector)
+		}
+		wg.Wait()
+
+		if len(resp.Errors) == len(clusters) {
+			writeInternalServerError(w, r, lastErr)
+			return
+		}
+		sort.Slice(resp.Queues, func(i, j int) bool {
+			if resp.Queues[i].Cluster != resp.Queues[j].Cluster {
+				return resp.Queues[i].Cluster < resp.Queues[j].Cluster
+			}
+			return resp.Queues[i].Queue < resp.Queues[j].Queue
+		})
+		sort.Slice(resp.Errors, func(i, j int) bool {
+			return resp.Errors[i].Cluster < resp.Errors[j].Cluster
+		})
+		writeResponseJSON(w, resp)
+	}
+}
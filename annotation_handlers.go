@@ -0,0 +1,246 @@
+package asynqmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - annotation store to attach free-form metadata to tasks
+//   - http.Handler(s) for task annotation endpoints
+// ****************************************************************************
+
+// DefaultAnnotationTTL is the default duration annotations of a task are kept for after they are last updated.
+const DefaultAnnotationTTL = 7 * 24 * time.Hour
+
+// Limits of annotations set by a single request.
+const (
+	maxAnnotationsPerRequest = 64
+	maxAnnotationKeyLength   = 128
+	maxAnnotationValueLength = 1024
+	maxBatchAnnotateTasks    = 1000
+)
+
+// annotationStore stores annotations of tasks in redis.
+// Annotations of a task are stored in a hash, which shares the hash tag of the queue's keys
+// so that it is stored on the same node in redis cluster.
+type annotationStore struct {
+	rc  redis.UniversalClient
+	ttl time.Duration
+}
+
+func annotationKey(qname, taskID string) string {
+	return fmt.Sprintf("asynqmon:{%s}:annotations:%s", qname, taskID)
+}
+
+// get returns the annotations of a task, or an empty map if the task has none.
+func (s *annotationStore) get(ctx context.Context, qname, taskID string) (map[string]string, error) {
+	return s.rc.HGetAll(ctx, annotationKey(qname, taskID)).Result()
+}
+
+// update sets the annotations of the tasks, removing the annotations with an empty value,
+// and resets the TTL of the annotations of each task.
+func (s *annotationStore) update(ctx context.Context, qname string, taskIDs []string, annotations map[string]string) error {
+	set := make(map[string]interface{})
+	var del []string
+	for k, v := range annotations {
+		if v == "" {
+			del = append(del, k)
+		} else {
+			set[k] = v
+		}
+	}
+	_, err := s.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range taskIDs {
+			key := annotationKey(qname, id)
+			if len(set) > 0 {
+				pipe.HSet(ctx, key, set)
+			}
+			if len(del) > 0 {
+				pipe.HDel(ctx, key, del...)
+			}
+			pipe.Expire(ctx, key, s.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// attach sets the annotations of the tasks which have any.
+// Failing to load annotations does not fail the request since they are supplementary,
+// so errors are only logged.
+func (s *annotationStore) attach(r *http.Request, qname string, tasks []*baseTask) {
+	if len(tasks) == 0 {
+		return
+	}
+	ctx := r.Context()
+	cmds := make([]*redis.StringStringMapCmd, len(tasks))
+	_, err := s.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, t := range tasks {
+			cmds[i] = pipe.HGetAll(ctx, annotationKey(qname, t.ID))
+		}
+		return nil
+	})
+	if err != nil {
+		logRequestf(r, "error: could not load annotations of tasks in queue %q: %v", qname, err)
+		return
+	}
+	for i, t := range tasks {
+		if m := cmds[i].Val(); len(m) > 0 {
+			t.Annotations = m
+		}
+	}
+}
+
+// attachTasks sets the annotations of tasks, a slice of pointers to task structs
+// embedding *baseTask as returned by the conversion helpers (e.g. []*pendingTask).
+func (s *annotationStore) attachTasks(r *http.Request, qname string, tasks interface{}) {
+	v := reflect.ValueOf(tasks)
+	bases := make([]*baseTask, v.Len())
+	for i := range bases {
+		bases[i] = v.Index(i).Interface().(interface{ base() *baseTask }).base()
+	}
+	s.attach(r, qname, bases)
+}
+
+// validateAnnotations returns an error if the annotations exceed the limits.
+func validateAnnotations(annotations map[string]string) error {
+	if len(annotations) == 0 {
+		return fmt.Errorf("annotations cannot be empty")
+	}
+	if len(annotations) > maxAnnotationsPerRequest {
+		return fmt.Errorf("too many annotations: %d (max %d)", len(annotations), maxAnnotationsPerRequest)
+	}
+	for k, v := range annotations {
+		if k == "" || len(k) > maxAnnotationKeyLength {
+			return fmt.Errorf("annotation key must be 1 to %d bytes: %q", maxAnnotationKeyLength, k)
+		}
+		if len(v) > maxAnnotationValueLength {
+			return fmt.Errorf("value of annotation %q exceeds %d bytes", k, maxAnnotationValueLength)
+		}
+	}
+	return nil
+}
+
+type annotateTaskRequest struct {
+	// Annotations to set; annotations with an empty value are removed.
+	Annotations map[string]string `json:"annotations"`
+}
+
+type annotationsResponse struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+// newGetTaskAnnotationsHandlerFunc returns a handler which returns the annotations of a task.
+func newGetTaskAnnotationsHandlerFunc(annotations *annotationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		m, err := annotations.get(r.Context(), vars["qname"], vars["task_id"])
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, annotationsResponse{Annotations: m})
+	}
+}
+
+// newAnnotateTaskHandlerFunc returns a handler which updates the annotations of a task
+// and returns all of its annotations.
+func newAnnotateTaskHandlerFunc(inspector *asynq.Inspector, annotations *annotationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		qname, taskID := vars["qname"], vars["task_id"]
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req annotateTaskRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if err := validateAnnotations(req.Annotations); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		_, err := inspector.GetTaskInfo(qname, taskID)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+		ctx := r.Context()
+		if err := annotations.update(ctx, qname, []string{taskID}, req.Annotations); err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		m, err := annotations.get(ctx, qname, taskID)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, annotationsResponse{Annotations: m})
+	}
+}
+
+type batchAnnotateTasksRequest struct {
+	TaskIDs []string `json:"task_ids"`
+	// Annotations to set on every task; annotations with an empty value are removed.
+	Annotations map[string]string `json:"annotations"`
+}
+
+type batchAnnotateTasksResponse struct {
+	// Number of tasks whose annotations were updated.
+	Updated int `json:"updated"`
+}
+
+// newBatchAnnotateTasksHandlerFunc returns a handler which updates the annotations of multiple tasks in a queue.
+// Unlike the single task endpoint, the existence of the tasks is not checked.
+func newBatchAnnotateTasksHandlerFunc(annotations *annotationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req batchAnnotateTasksRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if len(req.TaskIDs) == 0 {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "task_ids cannot be empty")
+			return
+		}
+		if len(req.TaskIDs) > maxBatchAnnotateTasks {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("too many task_ids: %d (max %d)", len(req.TaskIDs), maxBatchAnnotateTasks))
+			return
+		}
+		if err := validateAnnotations(req.Annotations); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		qname := mux.Vars(r)["qname"]
+		if err := annotations.update(r.Context(), qname, req.TaskIDs, req.Annotations); err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, batchAnnotateTasksResponse{Updated: len(req.TaskIDs)})
+	}
+}
@@ -0,0 +1,26 @@
+package asynqmon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAnnotations(t *testing.T) {
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{"Valid", map[string]string{"reviewed": "true", "owner": ""}, false},
+		{"Empty", map[string]string{}, true},
+		{"Empty key", map[string]string{"": "x"}, true},
+		{"Long key", map[string]string{strings.Repeat("k", maxAnnotationKeyLength+1): "x"}, true},
+		{"Long value", map[string]string{"note": strings.Repeat("v", maxAnnotationValueLength+1)}, true},
+	}
+
+	for _, tc := range tests {
+		if err := validateAnnotations(tc.annotations); (err != nil) != tc.wantErr {
+			t.Errorf("%s: validateAnnotations returned error %v, want error %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
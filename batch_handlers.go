@@ -0,0 +1,137 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for batch operation endpoints
+// ****************************************************************************
+
+// Maximum number of operations in a batch request.
+const maxBatchOperations = 100
+
+// Maximum number of task ids across all operations in a batch request.
+const maxBatchTaskIDs = 1000
+
+type batchOperation struct {
+	Queue string `json:"queue"`
+	// State of the tasks: "pending", "scheduled", "retry", "archived" or "completed".
+	State string `json:"state"`
+	// Action to perform: "run", "archive" or "delete".
+	Action  string   `json:"action"`
+	TaskIDs []string `json:"task_ids"`
+}
+
+type batchOperationResult struct {
+	Queue  string `json:"queue"`
+	State  string `json:"state"`
+	Action string `json:"action"`
+	// task ids that the action succeeded for.
+	SucceededIDs []string `json:"succeeded_ids"`
+	// task ids that the action failed for.
+	FailedIDs []string `json:"failed_ids"`
+	// Error is set if the operation is invalid, in which case no action is performed.
+	Error string `json:"error,omitempty"`
+}
+
+type batchRequest struct {
+	Operations []*batchOperation `json:"operations"`
+}
+
+type batchResponse struct {
+	// Results of the operations, in the same order as the operations in the request.
+	Results []*batchOperationResult `json:"results"`
+}
+
+// batchActionStates maps each batch action to the task states it can be performed on,
+// matching the actions available via the task endpoints.
+var batchActionStates = map[string][]string{
+	"run":     {"scheduled", "retry", "archived"},
+	"archive": {"pending", "scheduled", "retry"},
+	"delete":  {"pending", "scheduled", "retry", "archived", "completed"},
+}
+
+// newBatchHandlerFunc returns a handler which performs actions on tasks across multiple queues.
+// Operations are performed in order; like the other batch endpoints, a failure for a task
+// does not stop the rest of the tasks from being processed.
+func newBatchHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req batchRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if len(req.Operations) > maxBatchOperations {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("too many operations: at most %d operations are allowed", maxBatchOperations))
+			return
+		}
+		numIDs := 0
+		for _, op := range req.Operations {
+			numIDs += len(op.TaskIDs)
+		}
+		if numIDs > maxBatchTaskIDs {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("too many task ids: at most %d task ids are allowed", maxBatchTaskIDs))
+			return
+		}
+
+		resp := batchResponse{Results: make([]*batchOperationResult, len(req.Operations))}
+		for i, op := range req.Operations {
+			res := &batchOperationResult{
+				Queue:  op.Queue,
+				State:  op.State,
+				Action: op.Action,
+				// avoid null in the json response
+				SucceededIDs: make([]string, 0),
+				FailedIDs:    make([]string, 0),
+			}
+			resp.Results[i] = res
+			if err := validateBatchOperation(op); err != nil {
+				res.Error = err.Error()
+				continue
+			}
+			for _, taskid := range op.TaskIDs {
+				var err error
+				switch op.Action {
+				case "run":
+					err = inspector.RunTask(op.Queue, taskid)
+				case "archive":
+					err = inspector.ArchiveTask(op.Queue, taskid)
+				case "delete":
+					err = inspector.DeleteTask(op.Queue, taskid)
+				}
+				if err != nil {
+					logRequestf(r, "error: could not %s task with id %q in queue %q: %v", op.Action, taskid, op.Queue, err)
+					res.FailedIDs = append(res.FailedIDs, taskid)
+				} else {
+					res.SucceededIDs = append(res.SucceededIDs, taskid)
+				}
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+func validateBatchOperation(op *batchOperation) error {
+	if op.Queue == "" {
+		return fmt.Errorf("queue is required")
+	}
+	states, ok := batchActionStates[op.Action]
+	if !ok {
+		return fmt.Errorf("invalid action %q", op.Action)
+	}
+	if !contains(states, op.State) {
+		return fmt.Errorf("cannot %s tasks in %q state", op.Action, op.State)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// applyConfigFile sets the flags from the values in the YAML config file at path.
+// Keys of the file are flag names (e.g. "redis-addr"). A value in the file is only applied
+// if the flag was not set on the command line and its environment variable is not set,
+// so that command line flags take precedence over env vars, which take precedence over the file.
+// Unknown keys, and invalid env vars overriding a key, are reported as an error
+// rather than falling back to the default.
+func applyConfigFile(flags *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %v", err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("could not parse config file %q: %v", path, err)
+	}
+
+	var unknown []string
+	for name := range values {
+		if name == "config" || flags.Lookup(name) == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keys in config file %q: %s", path, strings.Join(unknown, ", "))
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, v := range values {
+		if set[name] {
+			continue
+		}
+		if env := os.Getenv(envName(name)); env != "" {
+			// The env var was only used as the default of the flag if it parsed, so set it again
+			// to report an invalid value instead of silently discarding both values.
+			if err := flags.Set(name, env); err != nil {
+				return fmt.Errorf("invalid value for env var %s: %v", envName(name), err)
+			}
+			continue
+		}
+		if err := flags.Set(name, configValueString(v)); err != nil {
+			return fmt.Errorf("invalid value for %q in config file %q: %v", name, path, err)
+		}
+	}
+	return nil
+}
+
+// envName returns the name of the environment variable for the flag.
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// configValueString returns the flag value string for a value in the config file.
+// Lists are joined with commas (e.g. redis-cluster-nodes).
+func configValueString(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		elems := make([]string, len(list))
+		for i, e := range list {
+			elems[i] = fmt.Sprint(e)
+		}
+		return strings.Join(elems, ",")
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hibiken/asynq"
+)
+
+// Default interval between runs of the archived task janitor.
+const defaultDeadTaskCleanupInterval = time.Hour
+
+// archivedTaskDeleter is the set of operations used by the janitor.
+type archivedTaskDeleter interface {
+	Queues() ([]string, error)
+	// archivedTaskIDs returns up to count IDs of the tasks in the queue archived before cutoff,
+	// oldest first, skipping the first offset ones.
+	archivedTaskIDs(qname string, cutoff time.Time, offset, count int64) ([]string, error)
+	DeleteTask(qname, id string) error
+}
+
+// janitorInspector implements archivedTaskDeleter with an inspector, reading archive times
+// from the scores of the archived task sorted set which the inspector does not expose.
+type janitorInspector struct {
+	*asynq.Inspector
+	rc redis.UniversalClient
+}
+
+func (i *janitorInspector) archivedTaskIDs(qname string, cutoff time.Time, offset, count int64) ([]string, error) {
+	// Archived tasks are scored by the unix time in seconds at which they were archived.
+	return i.rc.ZRangeByScore(context.Background(), fmt.Sprintf("asynq:{%s}:archived", qname), &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    "(" + strconv.FormatInt(cutoff.Unix(), 10),
+		Offset: offset,
+		Count:  count,
+	}).Result()
+}
+
+// deadTaskJanitor periodically deletes archived (dead) tasks which were archived more than ttl ago.
+type deadTaskJanitor struct {
+	inspector archivedTaskDeleter
+	ttl       time.Duration
+	interval  time.Duration
+
+	done chan struct{}
+}
+
+func newDeadTaskJanitor(inspector archivedTaskDeleter, ttl, interval time.Duration) *deadTaskJanitor {
+	return &deadTaskJanitor{
+		inspector: inspector,
+		ttl:       ttl,
+		interval:  interval,
+		done:      make(chan struct{}),
+	}
+}
+
+// start runs the janitor every interval until stop is called.
+func (j *deadTaskJanitor) start() {
+	log.Printf("deleting archived tasks older than %v every %v", j.ttl, j.interval)
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.run(time.Now())
+			case <-j.done:
+				return
+			}
+		}
+	}()
+}
+
+func (j *deadTaskJanitor) stop() {
+	close(j.done)
+}
+
+// run deletes the archived tasks of every queue which were archived before now minus ttl
+// and logs how many tasks were deleted per queue.
+func (j *deadTaskJanitor) run(now time.Time) {
+	qnames, err := j.inspector.Queues()
+	if err != nil {
+		log.Printf("error: janitor could not list queues: %v", err)
+		return
+	}
+	cutoff := now.Add(-j.ttl)
+	for _, qname := range qnames {
+		deleted, err := j.cleanQueue(qname, cutoff)
+		if err != nil {
+			log.Printf("error: janitor could not delete archived tasks of queue %q: %v", qname, err)
+		}
+		if deleted > 0 {
+			log.Printf("janitor deleted %d archived tasks older than %v from queue %q", deleted, j.ttl, qname)
+		}
+	}
+}
+
+// cleanQueue deletes the archived tasks in the queue which were archived before cutoff,
+// a batch at a time, and returns the number of tasks deleted.
+func (j *deadTaskJanitor) cleanQueue(qname string, cutoff time.Time) (int, error) {
+	const batchSize = 100
+	deleted := 0
+	// Deleted tasks leave the archived set, so only the IDs which could not be deleted are skipped.
+	var skipped int64
+	for {
+		ids, err := j.inspector.archivedTaskIDs(qname, cutoff, skipped, batchSize)
+		if err != nil {
+			return deleted, err
+		}
+		for _, id := range ids {
+			switch err := j.inspector.DeleteTask(qname, id); {
+			case errors.Is(err, asynq.ErrTaskNotFound):
+				// Deleted or run since it was listed.
+				skipped++
+			case err != nil:
+				return deleted, err
+			default:
+				deleted++
+			}
+		}
+		if len(ids) < batchSize {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hibiken/asynq"
+)
+
+type fakeArchivedTask struct {
+	id         string
+	archivedAt time.Time
+}
+
+// fakeArchivedTasks mimics the archived task sorted sets, with tasks sorted by archive time.
+type fakeArchivedTasks struct {
+	tasks   map[string][]fakeArchivedTask
+	deleted map[string][]string
+	// Calls to archivedTaskIDs, to check that the sets are paged through.
+	listCalls int
+}
+
+func (f *fakeArchivedTasks) Queues() ([]string, error) {
+	var qnames []string
+	for qname := range f.tasks {
+		qnames = append(qnames, qname)
+	}
+	sort.Strings(qnames)
+	return qnames, nil
+}
+
+func (f *fakeArchivedTasks) archivedTaskIDs(qname string, cutoff time.Time, offset, count int64) ([]string, error) {
+	f.listCalls++
+	var ids []string
+	for _, t := range f.tasks[qname] {
+		if !t.archivedAt.Before(cutoff) {
+			break
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if int64(len(ids)) == count {
+			break
+		}
+		ids = append(ids, t.id)
+	}
+	return ids, nil
+}
+
+func (f *fakeArchivedTasks) DeleteTask(qname, id string) error {
+	for i, t := range f.tasks[qname] {
+		if t.id == id {
+			f.tasks[qname] = append(f.tasks[qname][:i], f.tasks[qname][i+1:]...)
+			f.deleted[qname] = append(f.deleted[qname], id)
+			return nil
+		}
+	}
+	return asynq.ErrTaskNotFound
+}
+
+func TestDeadTaskJanitorRun(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeArchivedTasks{
+		tasks: map[string][]fakeArchivedTask{
+			"default": {
+				{id: "old", archivedAt: now.Add(-48 * time.Hour)},
+				{id: "new", archivedAt: now.Add(-time.Hour)},
+			},
+			"low": {
+				{id: "old2", archivedAt: now.Add(-25 * time.Hour)},
+			},
+		},
+		deleted: make(map[string][]string),
+	}
+
+	newDeadTaskJanitor(f, 24*time.Hour, time.Hour).run(now)
+
+	want := map[string][]string{"default": {"old"}, "low": {"old2"}}
+	if diff := cmp.Diff(want, f.deleted); diff != "" {
+		t.Errorf("deleted tasks mismatch (-want,+got):\n%s", diff)
+	}
+}
+
+func TestDeadTaskJanitorRunPaging(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeArchivedTasks{
+		tasks:   map[string][]fakeArchivedTask{"default": nil},
+		deleted: make(map[string][]string),
+	}
+	var want []string
+	for i := 0; i < 250; i++ {
+		id := fmt.Sprintf("task%d", i)
+		f.tasks["default"] = append(f.tasks["default"], fakeArchivedTask{id: id, archivedAt: now.Add(-48 * time.Hour)})
+		want = append(want, id)
+	}
+	f.tasks["default"] = append(f.tasks["default"], fakeArchivedTask{id: "new", archivedAt: now})
+
+	newDeadTaskJanitor(f, 24*time.Hour, time.Hour).run(now)
+
+	if diff := cmp.Diff(map[string][]string{"default": want}, f.deleted); diff != "" {
+		t.Errorf("deleted tasks mismatch (-want,+got):\n%s", diff)
+	}
+	if f.listCalls != 3 {
+		t.Errorf("archivedTaskIDs called %d times, want 3", f.listCalls)
+	}
+}
@@ -2,29 +2,50 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/hibiken/asynq"
 	"github.com/hibiken/asynq/x/metrics"
 	"github.com/hibiken/asynqmon"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config holds configurations for the program provided via the command line.
 type Config struct {
+	// Path to a YAML file which sets the values of flags
+	ConfigFile string
+
 	// Server port
 	Port int
 
+	// Server address; either a TCP address (e.g. "localhost:8080") or
+	// a Unix domain socket path prefixed with "unix:" (e.g. "unix:/var/run/asynqmon.sock").
+	// If empty, the server listens on all interfaces on Port.
+	Addr string
+
+	// Format of access logs: "text", "apache" or "json"
+	LogFormat string
+
+	// Maximum duration to write a response, which also bounds streaming responses; zero disables the timeout
+	WriteTimeout time.Duration
+
 	// Redis connection options
 	RedisAddr         string
 	RedisDB           int
@@ -34,11 +55,67 @@ type Config struct {
 	RedisInsecureTLS  bool
 	RedisClusterNodes string
 
+	// Redis connection pool options
+	RedisPoolSize     int
+	RedisMinIdleConns int
+	RedisDialTimeout  time.Duration
+
+	// Maximum duration to wait for redis to be reachable at startup; zero disables the check
+	RedisStartupTimeout time.Duration
+
 	// UI related configs
 	ReadOnly         bool
 	MaxPayloadLength int
 	MaxResultLength  int
 
+	// Maximum number of bytes of a payload included in API responses; zero disables truncation
+	MaxPayloadDisplayBytes int
+	UIAssetsDir            string
+
+	// API route groups to disable
+	DisableServersAPI   bool
+	DisableSchedulerAPI bool
+
+	// Serve the redis slowlog and latency endpoints
+	EnableRedisDiagnostics bool
+
+	// Name of the cluster to label its queues in aggregate endpoints
+	ClusterName string
+
+	// Additional clusters to aggregate, in the form of "name1=redis://host:port,name2=redis://host:port"
+	AggregateClusters string
+
+	// Interval to refresh the cached queue stats; zero disables the cache
+	StatsCacheInterval time.Duration
+
+	// Duration to remember responses of requests with an Idempotency-Key header
+	IdempotencyKeyTTL time.Duration
+
+	// Duration to keep task annotations after they are last updated
+	AnnotationTTL time.Duration
+
+	// Age after which archived tasks are deleted; zero disables the cleanup
+	DeadTaskTTL time.Duration
+
+	// Interval between cleanups of archived tasks
+	DeadTaskCleanupInterval time.Duration
+
+	// Default and maximum page sizes of task lists per queue, in the form of "queue1=default:max,queue2=default"
+	QueuePageSizes string
+
+	// Payload fields to redact in the UI, in the form of "type1=path1,path2;type2=path3"
+	PayloadRedactions string
+
+	// Path to a JSON file which maps task types to JSON schemas for payload validation
+	PayloadSchemasFile string
+
+	// Profiling related configs
+	EnablePprof bool
+	PprofAddr   string
+
+	// URL of the OTLP/HTTP collector to export traces to; tracing is disabled if empty
+	OtelEndpoint string
+
 	// Prometheus related configs
 	EnableMetricsExporter bool
 	PrometheusServerAddr  string
@@ -59,7 +136,11 @@ func parseFlags(progname string, args []string) (cfg *Config, output string, err
 	flags.SetOutput(&buf)
 
 	var conf Config
+	flags.StringVar(&conf.ConfigFile, "config", getEnvDefaultString("CONFIG", ""), "path to a YAML file setting flag values by flag name; command line flags and env vars take precedence")
 	flags.IntVar(&conf.Port, "port", getEnvOrDefaultInt("PORT", 8080), "port number to use for web ui server")
+	flags.StringVar(&conf.Addr, "addr", getEnvDefaultString("ADDR", ""), "address to listen on; TCP address or unix socket path prefixed with \"unix:\" (overrides --port if set)")
+	flags.StringVar(&conf.LogFormat, "log-format", getEnvDefaultString("LOG_FORMAT", logFormatText), "format of access logs; one of \"text\" (common log format), \"apache\" (combined log format) or \"json\"")
+	flags.DurationVar(&conf.WriteTimeout, "write-timeout", getEnvOrDefaultDuration("WRITE_TIMEOUT", 10*time.Second), "maximum duration to write a response, including queue exports, NDJSON task streams and pprof profiles on the main listener (0 disables the timeout)")
 	flags.StringVar(&conf.RedisAddr, "redis-addr", getEnvDefaultString("REDIS_ADDR", "127.0.0.1:6379"), "address of redis server to connect to")
 	flags.IntVar(&conf.RedisDB, "redis-db", getEnvOrDefaultInt("REDIS_DB", 0), "redis database number")
 	flags.StringVar(&conf.RedisPassword, "redis-password", getEnvDefaultString("REDIS_PASSWORD", ""), "password to use when connecting to redis server")
@@ -67,16 +148,43 @@ func parseFlags(progname string, args []string) (cfg *Config, output string, err
 	flags.StringVar(&conf.RedisURL, "redis-url", getEnvDefaultString("REDIS_URL", ""), "URL to redis server")
 	flags.BoolVar(&conf.RedisInsecureTLS, "redis-insecure-tls", getEnvOrDefaultBool("REDIS_INSECURE_TLS", false), "disable TLS certificate host checks")
 	flags.StringVar(&conf.RedisClusterNodes, "redis-cluster-nodes", getEnvDefaultString("REDIS_CLUSTER_NODES", ""), "comma separated list of host:port addresses of cluster nodes")
+	flags.IntVar(&conf.RedisPoolSize, "redis-pool-size", getEnvOrDefaultInt("REDIS_POOL_SIZE", 0), "maximum number of socket connections to redis (0 uses the go-redis default of 10 per CPU)")
+	flags.IntVar(&conf.RedisMinIdleConns, "redis-min-idle-conns", getEnvOrDefaultInt("REDIS_MIN_IDLE_CONNS", 0), "minimum number of idle connections to keep open to redis")
+	flags.DurationVar(&conf.RedisDialTimeout, "redis-dial-timeout", getEnvOrDefaultDuration("REDIS_DIAL_TIMEOUT", 5*time.Second), "timeout for establishing new connections to redis")
+	flags.DurationVar(&conf.RedisStartupTimeout, "redis-startup-timeout", getEnvOrDefaultDuration("REDIS_STARTUP_TIMEOUT", 30*time.Second), "maximum duration to wait for redis to be reachable before serving; retries with exponential backoff (0 disables the check)")
+	flags.DurationVar(&conf.StatsCacheInterval, "stats-cache-interval", getEnvOrDefaultDuration("STATS_CACHE_INTERVAL", 0), "interval to refresh the cached queue stats served to the web UI (0 disables the cache)")
+	flags.DurationVar(&conf.IdempotencyKeyTTL, "idempotency-key-ttl", getEnvOrDefaultDuration("IDEMPOTENCY_KEY_TTL", asynqmon.DefaultIdempotencyKeyTTL), "duration to remember responses of mutating requests with an Idempotency-Key header")
+	flags.DurationVar(&conf.AnnotationTTL, "annotation-ttl", getEnvOrDefaultDuration("ANNOTATION_TTL", asynqmon.DefaultAnnotationTTL), "duration to keep task annotations after they are last updated")
+	flags.DurationVar(&conf.DeadTaskTTL, "dead-task-ttl", getEnvOrDefaultDuration("DEAD_TASK_TTL", 0), "age after which archived tasks are deleted in the background (0 disables the cleanup; cannot be used with --read-only)")
+	flags.DurationVar(&conf.DeadTaskCleanupInterval, "dead-task-cleanup-interval", getEnvOrDefaultDuration("DEAD_TASK_CLEANUP_INTERVAL", defaultDeadTaskCleanupInterval), "interval between cleanups of archived tasks older than --dead-task-ttl")
 	flags.IntVar(&conf.MaxPayloadLength, "max-payload-length", getEnvOrDefaultInt("MAX_PAYLOAD_LENGTH", 200), "maximum number of utf8 characters printed in the payload cell in the Web UI")
 	flags.IntVar(&conf.MaxResultLength, "max-result-length", getEnvOrDefaultInt("MAX_RESULT_LENGTH", 200), "maximum number of utf8 characters printed in the result cell in the Web UI")
+	flags.IntVar(&conf.MaxPayloadDisplayBytes, "max-payload-display-bytes", getEnvOrDefaultInt("MAX_PAYLOAD_DISPLAY_BYTES", 0), "maximum number of bytes of a payload included in API responses; larger payloads are truncated (0 disables truncation)")
+	flags.StringVar(&conf.UIAssetsDir, "ui-assets-dir", getEnvDefaultString("UI_ASSETS_DIR", ""), "directory to serve web UI assets from (serves the assets embedded in the binary if empty)")
+	flags.StringVar(&conf.QueuePageSizes, "queue-page-sizes", getEnvDefaultString("QUEUE_PAGE_SIZES", ""), "comma separated list of queue names and default:max page sizes of their task lists (e.g. \"critical=10:50,low=100\"); other queues default to 20 tasks per page")
+	flags.StringVar(&conf.PayloadRedactions, "payload-redactions", getEnvDefaultString("PAYLOAD_REDACTIONS", ""), "semicolon separated list of task types and comma separated JSON field paths to redact in payloads (e.g. \"email:send=to,user.ssn;payment=card.number\")")
+	flags.StringVar(&conf.PayloadSchemasFile, "payload-schemas", getEnvDefaultString("PAYLOAD_SCHEMAS", ""), "path to a JSON file mapping task types to JSON schemas used to validate payloads of enqueued tasks")
 	flags.BoolVar(&conf.EnableMetricsExporter, "enable-metrics-exporter", getEnvOrDefaultBool("ENABLE_METRICS_EXPORTER", false), "enable prometheus metrics exporter to expose queue metrics")
 	flags.StringVar(&conf.PrometheusServerAddr, "prometheus-addr", getEnvDefaultString("PROMETHEUS_ADDR", ""), "address of prometheus server to query time series")
+	flags.StringVar(&conf.OtelEndpoint, "otel-endpoint", getEnvDefaultString("OTEL_ENDPOINT", ""), "URL of OTLP/HTTP collector to export OpenTelemetry traces to (e.g. \"http://localhost:4318\"); tracing is disabled if empty")
 	flags.BoolVar(&conf.ReadOnly, "read-only", getEnvOrDefaultBool("READ_ONLY", false), "restrict to read-only mode")
+	flags.BoolVar(&conf.DisableServersAPI, "disable-servers-api", getEnvOrDefaultBool("DISABLE_SERVERS_API", false), "disable the /api/servers and /api/queues:priorities endpoints")
+	flags.BoolVar(&conf.DisableSchedulerAPI, "disable-scheduler-api", getEnvOrDefaultBool("DISABLE_SCHEDULER_API", false), "disable the /api/scheduler_entries endpoints")
+	flags.BoolVar(&conf.EnableRedisDiagnostics, "enable-redis-diagnostics", getEnvOrDefaultBool("ENABLE_REDIS_DIAGNOSTICS", false), "serve the /api/redis/slowlog and /api/redis/latency endpoints (exposes redis commands and their arguments)")
+	flags.StringVar(&conf.ClusterName, "cluster-name", getEnvDefaultString("CLUSTER_NAME", "default"), "name of the cluster to label its queues in the /api/aggregate/queues endpoint")
+	flags.StringVar(&conf.AggregateClusters, "aggregate-clusters", getEnvDefaultString("AGGREGATE_CLUSTERS", ""), "comma separated list of names and redis URLs of additional clusters listed by the /api/aggregate/queues endpoint (e.g. \"eu=redis://eu-redis:6379,us=redis://us-redis:6379/1\")")
+	flags.BoolVar(&conf.EnablePprof, "enable-pprof", getEnvOrDefaultBool("ENABLE_PPROF", false), "expose pprof profiling endpoints under /debug/pprof/ (never expose publicly)")
+	flags.StringVar(&conf.PprofAddr, "pprof-addr", getEnvDefaultString("PPROF_ADDR", ""), "loopback address to serve pprof endpoints on a separate listener (serves on the main server if empty)")
 
 	err = flags.Parse(args)
 	if err != nil {
 		return nil, buf.String(), err
 	}
+	if conf.ConfigFile != "" {
+		if err := applyConfigFile(flags, conf.ConfigFile); err != nil {
+			return nil, buf.String(), err
+		}
+	}
 	conf.Args = flags.Args()
 	return &conf, buf.String(), nil
 }
@@ -94,11 +202,12 @@ func makeTLSConfig(cfg *Config) *tls.Config {
 func makeRedisConnOpt(cfg *Config) (asynq.RedisConnOpt, error) {
 	// Connecting to redis-cluster
 	if len(cfg.RedisClusterNodes) > 0 {
-		return asynq.RedisClusterClientOpt{
-			Addrs:     strings.Split(cfg.RedisClusterNodes, ","),
-			Password:  cfg.RedisPassword,
-			TLSConfig: makeTLSConfig(cfg),
-		}, nil
+		return withPoolOptions(asynq.RedisClusterClientOpt{
+			Addrs:       strings.Split(cfg.RedisClusterNodes, ","),
+			Password:    cfg.RedisPassword,
+			DialTimeout: cfg.RedisDialTimeout,
+			TLSConfig:   makeTLSConfig(cfg),
+		}, cfg), nil
 	}
 
 	// Connecting to redis-sentinels
@@ -109,7 +218,9 @@ func makeRedisConnOpt(cfg *Config) (asynq.RedisConnOpt, error) {
 		}
 		connOpt := res.(asynq.RedisFailoverClientOpt) // safe to type-assert
 		connOpt.TLSConfig = makeTLSConfig(cfg)
-		return connOpt, nil
+		connOpt.PoolSize = cfg.RedisPoolSize
+		connOpt.DialTimeout = cfg.RedisDialTimeout
+		return withPoolOptions(connOpt, cfg), nil
 	}
 
 	// Connecting to single redis server
@@ -128,7 +239,81 @@ func makeRedisConnOpt(cfg *Config) (asynq.RedisConnOpt, error) {
 	if connOpt.TLSConfig == nil {
 		connOpt.TLSConfig = makeTLSConfig(cfg)
 	}
-	return connOpt, nil
+	connOpt.PoolSize = cfg.RedisPoolSize
+	connOpt.DialTimeout = cfg.RedisDialTimeout
+	return withPoolOptions(connOpt, cfg), nil
+}
+
+// withPoolOptions wraps connOpt to apply the connection pool options which
+// cannot be set with asynq's RedisConnOpt types.
+// If no such options are specified, connOpt is returned as is.
+func withPoolOptions(connOpt asynq.RedisConnOpt, cfg *Config) asynq.RedisConnOpt {
+	_, isCluster := connOpt.(asynq.RedisClusterClientOpt)
+	if cfg.RedisMinIdleConns == 0 && (!isCluster || cfg.RedisPoolSize == 0) {
+		return connOpt
+	}
+	return poolRedisConnOpt{
+		RedisConnOpt: connOpt,
+		poolSize:     cfg.RedisPoolSize,
+		minIdleConns: cfg.RedisMinIdleConns,
+	}
+}
+
+// poolRedisConnOpt is a RedisConnOpt which creates redis clients with
+// the given connection pool options.
+type poolRedisConnOpt struct {
+	asynq.RedisConnOpt
+
+	poolSize     int
+	minIdleConns int
+}
+
+func (opt poolRedisConnOpt) MakeRedisClient() interface{} {
+	switch o := opt.RedisConnOpt.(type) {
+	case asynq.RedisClientOpt:
+		return redis.NewClient(&redis.Options{
+			Network:      o.Network,
+			Addr:         o.Addr,
+			Username:     o.Username,
+			Password:     o.Password,
+			DB:           o.DB,
+			DialTimeout:  o.DialTimeout,
+			ReadTimeout:  o.ReadTimeout,
+			WriteTimeout: o.WriteTimeout,
+			PoolSize:     opt.poolSize,
+			MinIdleConns: opt.minIdleConns,
+			TLSConfig:    o.TLSConfig,
+		})
+	case asynq.RedisFailoverClientOpt:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       o.MasterName,
+			SentinelAddrs:    o.SentinelAddrs,
+			SentinelPassword: o.SentinelPassword,
+			Username:         o.Username,
+			Password:         o.Password,
+			DB:               o.DB,
+			DialTimeout:      o.DialTimeout,
+			ReadTimeout:      o.ReadTimeout,
+			WriteTimeout:     o.WriteTimeout,
+			PoolSize:         opt.poolSize,
+			MinIdleConns:     opt.minIdleConns,
+			TLSConfig:        o.TLSConfig,
+		})
+	case asynq.RedisClusterClientOpt:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        o.Addrs,
+			MaxRedirects: o.MaxRedirects,
+			Username:     o.Username,
+			Password:     o.Password,
+			DialTimeout:  o.DialTimeout,
+			ReadTimeout:  o.ReadTimeout,
+			WriteTimeout: o.WriteTimeout,
+			PoolSize:     opt.poolSize,
+			MinIdleConns: opt.minIdleConns,
+			TLSConfig:    o.TLSConfig,
+		})
+	}
+	return opt.RedisConnOpt.MakeRedisClient()
 }
 
 func main() {
@@ -147,23 +332,54 @@ func main() {
 		log.Fatal(err)
 	}
 
-	h := asynqmon.New(asynqmon.Options{
-		RedisConnOpt:      redisConnOpt,
-		PayloadFormatter:  asynqmon.PayloadFormatterFunc(payloadFormatterFunc(cfg)),
-		ResultFormatter:   asynqmon.ResultFormatterFunc(resultFormatterFunc(cfg)),
-		PrometheusAddress: cfg.PrometheusServerAddr,
-		ReadOnly:          cfg.ReadOnly,
-	})
-	defer h.Close()
+	if cfg.RedisStartupTimeout > 0 {
+		rc := redisConnOpt.MakeRedisClient().(redis.UniversalClient)
+		err := waitForRedis(func(ctx context.Context) error { return rc.Ping(ctx).Err() }, cfg.RedisStartupTimeout)
+		rc.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	c := cors.New(cors.Options{
-		AllowedMethods: []string{"GET", "POST", "DELETE"},
-	})
-	mux := http.NewServeMux()
-	mux.Handle("/", c.Handler(h))
+	payloadRedactions, err := parsePayloadRedactions(cfg.PayloadRedactions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	queuePageSizes, err := parseQueuePageSizes(cfg.QueuePageSizes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	aggregateClusters, err := parseAggregateClusters(cfg.AggregateClusters)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.UIAssetsDir != "" {
+		if fi, err := os.Stat(filepath.Join(cfg.UIAssetsDir, "index.html")); err != nil || fi.IsDir() {
+			log.Printf("warning: %q does not contain index.html; web UI will not be available", cfg.UIAssetsDir)
+		}
+	}
+
+	logging, err := newLoggingMiddleware(cfg.LogFormat, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var payloadValidator asynqmon.PayloadValidator
+	if cfg.PayloadSchemasFile != "" {
+		v, err := loadPayloadSchemas(cfg.PayloadSchemasFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		payloadValidator = v
+	}
+
+	var reg *prometheus.Registry
 	if cfg.EnableMetricsExporter {
 		// Using NewPedanticRegistry here to test the implementation of Collectors and Metrics.
-		reg := prometheus.NewPedanticRegistry()
+		reg = prometheus.NewPedanticRegistry()
 
 		inspector := asynq.NewInspector(redisConnOpt)
 
@@ -173,18 +389,163 @@ func main() {
 			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
 			prometheus.NewGoCollector(),
 		)
+	}
+
+	if cfg.DeadTaskTTL > 0 {
+		if cfg.ReadOnly {
+			log.Fatal("--dead-task-ttl cannot be used with --read-only")
+		}
+		if cfg.DeadTaskCleanupInterval <= 0 {
+			log.Fatalf("--dead-task-cleanup-interval must be positive: %v", cfg.DeadTaskCleanupInterval)
+		}
+		inspector := asynq.NewInspector(redisConnOpt)
+		defer inspector.Close()
+		rc := redisConnOpt.MakeRedisClient().(redis.UniversalClient)
+		defer rc.Close()
+		janitor := newDeadTaskJanitor(&janitorInspector{Inspector: inspector, rc: rc}, cfg.DeadTaskTTL, cfg.DeadTaskCleanupInterval)
+		janitor.start()
+		defer janitor.stop()
+	}
+
+	var tracerProvider trace.TracerProvider
+	if cfg.OtelEndpoint != "" {
+		tp, err := newTracerProvider(context.Background(), cfg.OtelEndpoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				log.Printf("error: could not flush traces: %v", err)
+			}
+		}()
+		tracerProvider = tp
+	}
+
+	h := asynqmon.New(asynqmon.Options{
+		RedisConnOpt:              redisConnOpt,
+		PayloadFormatter:          asynqmon.PayloadFormatterFunc(payloadFormatterFunc(cfg)),
+		ResultFormatter:           asynqmon.ResultFormatterFunc(resultFormatterFunc(cfg)),
+		PayloadRedactions:         payloadRedactions,
+		MaxPayloadDisplayBytes:    cfg.MaxPayloadDisplayBytes,
+		QueuePageSizes:            queuePageSizes,
+		PayloadValidator:          payloadValidator,
+		PrometheusAddress:         cfg.PrometheusServerAddr,
+		ReadOnly:                  cfg.ReadOnly,
+		DisableServersAPI:         cfg.DisableServersAPI,
+		DisableSchedulerAPI:       cfg.DisableSchedulerAPI,
+		EnableRedisDiagnosticsAPI: cfg.EnableRedisDiagnostics,
+		ClusterName:               cfg.ClusterName,
+		AggregateClusters:         aggregateClusters,
+		UIAssetsDir:               cfg.UIAssetsDir,
+		StatsCacheInterval:        cfg.StatsCacheInterval,
+		MetricsRegisterer:         metricsRegisterer(reg),
+		IdempotencyKeyTTL:         cfg.IdempotencyKeyTTL,
+		AnnotationTTL:             cfg.AnnotationTTL,
+		TracerProvider:            tracerProvider,
+	})
+	defer h.Close()
+
+	c := cors.New(cors.Options{
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", asynqmon.IdempotencyKeyHeader, "traceparent", "tracestate"},
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+	if cfg.EnableMetricsExporter {
 		mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 	}
+	if cfg.EnablePprof {
+		if cfg.PprofAddr == "" {
+			if cfg.WriteTimeout > 0 && cfg.WriteTimeout <= defaultProfileDuration {
+				log.Printf("warning: --write-timeout=%v rejects pprof profiles of the default %v duration; pass a shorter ?seconds=, or use --pprof-addr or a longer --write-timeout", cfg.WriteTimeout, defaultProfileDuration)
+			}
+			mux.Handle("/debug/pprof/", newPprofHandler())
+		} else {
+			addr, err := pprofListenAddr(cfg.PprofAddr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			go func() {
+				log.Printf("pprof server is listening on %s", addr)
+				if err := http.ListenAndServe(addr, newPprofHandler()); err != nil {
+					log.Printf("error: pprof server: %v", err)
+				}
+			}()
+		}
+	}
 
+	// Wrap the whole mux so that /metrics and /debug/pprof/ go through the plugins (e.g. authentication) too.
+	mws := append([]Middleware{asynqmon.RequestIDMiddleware, logging, c.Handler}, pluginMiddlewares...)
 	srv := &http.Server{
-		Handler:      mux,
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		WriteTimeout: 10 * time.Second,
+		Handler:      chain(mux, mws...),
+		WriteTimeout: cfg.WriteTimeout,
 		ReadTimeout:  10 * time.Second,
 	}
 
-	fmt.Printf("Asynq Monitoring WebUI server is listening on port %d\n", cfg.Port)
-	log.Fatal(srv.ListenAndServe())
+	network, addr := listenAddr(cfg)
+	ln, err := listen(network, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Shutdown gracefully on signal so that the unix socket file gets removed.
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("error: server shutdown: %v", err)
+		}
+		close(idleConnsClosed)
+	}()
+
+	fmt.Printf("Asynq Monitoring WebUI server is listening on %s\n", ln.Addr())
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-idleConnsClosed
+}
+
+// metricsRegisterer returns reg as a prometheus.Registerer, or nil if reg is nil.
+// This avoids passing a non-nil interface holding a nil pointer to asynqmon.Options.
+func metricsRegisterer(reg *prometheus.Registry) prometheus.Registerer {
+	if reg == nil {
+		return nil
+	}
+	return reg
+}
+
+// unixAddrPrefix is the prefix of --addr flag value to specify a unix domain socket path.
+const unixAddrPrefix = "unix:"
+
+// listenAddr returns the network and address to listen on.
+func listenAddr(cfg *Config) (network, addr string) {
+	if strings.HasPrefix(cfg.Addr, unixAddrPrefix) {
+		return "unix", strings.TrimPrefix(cfg.Addr, unixAddrPrefix)
+	}
+	if cfg.Addr != "" {
+		return "tcp", cfg.Addr
+	}
+	return "tcp", fmt.Sprintf(":%d", cfg.Port)
+}
+
+// listen announces on the network address.
+// For unix domain sockets, a stale socket file left by a previous process is removed first.
+// The socket file is removed when the listener is closed.
+func listen(network, addr string) (net.Listener, error) {
+	if network == "unix" {
+		if fi, err := os.Stat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(addr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return net.Listen(network, addr)
 }
 
 func payloadFormatterFunc(cfg *Config) func(string, []byte) string {
@@ -201,6 +562,92 @@ func resultFormatterFunc(cfg *Config) func(string, []byte) string {
 	}
 }
 
+// parsePayloadRedactions parses the value of --payload-redactions flag
+// and returns a map of task type to the list of field paths to redact.
+func parsePayloadRedactions(s string) (map[string][]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	res := make(map[string][]string)
+	for _, rule := range strings.Split(s, ";") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid payload redaction rule %q: want format \"type=path1,path2\"", rule)
+		}
+		for _, path := range strings.Split(kv[1], ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				res[kv[0]] = append(res[kv[0]], path)
+			}
+		}
+	}
+	return res, nil
+}
+
+// parseQueuePageSizes parses the value of --queue-page-sizes flag
+// and returns a map of queue name to the page sizes of its task lists.
+// The max page size can be omitted (e.g. "low=100").
+func parseQueuePageSizes(s string) (map[string]asynqmon.PageSizes, error) {
+	if s == "" {
+		return nil, nil
+	}
+	res := make(map[string]asynqmon.PageSizes)
+	for _, rule := range strings.Split(s, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid queue page size %q: want format \"queue=default:max\"", rule)
+		}
+		var sizes asynqmon.PageSizes
+		vals := strings.SplitN(kv[1], ":", 2)
+		n, err := strconv.Atoi(vals[0])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid queue page size %q: default must be a positive integer", rule)
+		}
+		sizes.Default = n
+		if len(vals) == 2 {
+			n, err := strconv.Atoi(vals[1])
+			if err != nil || n < sizes.Default {
+				return nil, fmt.Errorf("invalid queue page size %q: max must be an integer no less than default", rule)
+			}
+			sizes.Max = n
+		}
+		res[kv[0]] = sizes
+	}
+	return res, nil
+}
+
+// parseAggregateClusters parses the value of --aggregate-clusters flag
+// and returns a map of cluster name to its redis connection options.
+func parseAggregateClusters(s string) (map[string]asynq.RedisConnOpt, error) {
+	if s == "" {
+		return nil, nil
+	}
+	res := make(map[string]asynq.RedisConnOpt)
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid aggregate cluster %q: want format \"name=redis://host:port\"", c)
+		}
+		if _, ok := res[kv[0]]; ok {
+			return nil, fmt.Errorf("duplicate aggregate cluster name %q", kv[0])
+		}
+		connOpt, err := asynq.ParseRedisURI(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid aggregate cluster %q: %v", kv[0], err)
+		}
+		res[kv[0]] = connOpt
+	}
+	return res, nil
+}
+
 // truncates string s to limit length (in utf8).
 func truncate(s string, limit int) string {
 	i := 0
@@ -230,6 +677,14 @@ func getEnvOrDefaultInt(key string, def int) int {
 	return v
 }
 
+func getEnvOrDefaultDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
 func getEnvOrDefaultBool(key string, def bool) bool {
 	v, err := strconv.ParseBool(os.Getenv(key))
 	if err != nil {
@@ -2,12 +2,17 @@ package main
 
 import (
 	"crypto/tls"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/hibiken/asynq"
+	"github.com/hibiken/asynqmon"
 )
 
 func TestParseFlags(t *testing.T) {
@@ -22,17 +27,41 @@ func TestParseFlags(t *testing.T) {
 				RedisDB:   3,
 
 				// Default values
-				Port:                  8080,
-				RedisPassword:         "",
-				RedisTLS:              "",
-				RedisURL:              "",
-				RedisInsecureTLS:      false,
-				RedisClusterNodes:     "",
-				MaxPayloadLength:      200,
-				MaxResultLength:       200,
-				EnableMetricsExporter: false,
-				PrometheusServerAddr:  "",
-				ReadOnly:              false,
+				ConfigFile:              "",
+				Port:                    8080,
+				Addr:                    "",
+				LogFormat:               "text",
+				WriteTimeout:            10 * time.Second,
+				RedisPassword:           "",
+				RedisTLS:                "",
+				RedisURL:                "",
+				RedisInsecureTLS:        false,
+				RedisClusterNodes:       "",
+				RedisPoolSize:           0,
+				RedisMinIdleConns:       0,
+				RedisDialTimeout:        5 * time.Second,
+				RedisStartupTimeout:     30 * time.Second,
+				MaxPayloadLength:        200,
+				MaxResultLength:         200,
+				MaxPayloadDisplayBytes:  0,
+				UIAssetsDir:             "",
+				StatsCacheInterval:      0,
+				IdempotencyKeyTTL:       24 * time.Hour,
+				AnnotationTTL:           7 * 24 * time.Hour,
+				DeadTaskCleanupInterval: time.Hour,
+				QueuePageSizes:          "",
+				PayloadRedactions:       "",
+				PayloadSchemasFile:      "",
+				EnablePprof:             false,
+				PprofAddr:               "",
+				EnableMetricsExporter:   false,
+				PrometheusServerAddr:    "",
+				ReadOnly:                false,
+				DisableServersAPI:       false,
+				DisableSchedulerAPI:     false,
+				EnableRedisDiagnostics:  false,
+				ClusterName:             "default",
+				AggregateClusters:       "",
 
 				Args: []string{},
 			},
@@ -56,6 +85,63 @@ func TestParseFlags(t *testing.T) {
 
 }
 
+func TestParseFlagsWithConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asynqmon.yaml")
+	content := `
+port: 9090
+redis-addr: localhost:6380
+redis-db: 2
+redis-cluster-nodes: [localhost:7000, localhost:7001]
+read-only: true
+stats-cache-interval: 5s
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("REDIS_DB", "4")
+	defer os.Unsetenv("REDIS_DB")
+
+	cfg, _, err := parseFlags("asynqmon", []string{"--config", path, "--port", "3000"})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if cfg.Port != 3000 {
+		t.Errorf("Port = %d, want the command line value 3000", cfg.Port)
+	}
+	if cfg.RedisDB != 4 {
+		t.Errorf("RedisDB = %d, want the env value 4", cfg.RedisDB)
+	}
+	if cfg.RedisAddr != "localhost:6380" || cfg.RedisClusterNodes != "localhost:7000,localhost:7001" ||
+		!cfg.ReadOnly || cfg.StatsCacheInterval != 5*time.Second {
+		t.Errorf("parseFlags did not apply values from config file: %+v", cfg)
+	}
+}
+
+func TestParseFlagsWithInvalidEnvOverridingConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asynqmon.yaml")
+	if err := os.WriteFile(path, []byte("redis-db: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("REDIS_DB", "four")
+	defer os.Unsetenv("REDIS_DB")
+
+	_, _, err := parseFlags("asynqmon", []string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), "REDIS_DB") {
+		t.Errorf("parseFlags returned error %v, want error reporting invalid env var %q", err, "REDIS_DB")
+	}
+}
+
+func TestParseFlagsWithUnknownConfigKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asynqmon.yaml")
+	if err := os.WriteFile(path, []byte("redis-adr: localhost:6380\nport: 9090\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := parseFlags("asynqmon", []string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), "redis-adr") {
+		t.Errorf("parseFlags returned error %v, want error reporting unknown key %q", err, "redis-adr")
+	}
+}
+
 func TestMakeRedisConnOpt(t *testing.T) {
 	var tests = []struct {
 		desc string
@@ -119,6 +205,48 @@ func TestMakeRedisConnOpt(t *testing.T) {
 					"localhost:5000", "localhost:5001", "localhost:5002", "localhost:5003", "localhost:5004", "localhost:5005"},
 			},
 		},
+		{
+			desc: "With pool size and dial timeout",
+			cfg: &Config{
+				RedisAddr:        "localhost:6379",
+				RedisPoolSize:    50,
+				RedisDialTimeout: 3 * time.Second,
+			},
+			want: asynq.RedisClientOpt{
+				Addr:        "localhost:6379",
+				PoolSize:    50,
+				DialTimeout: 3 * time.Second,
+			},
+		},
+		{
+			desc: "With min idle connections",
+			cfg: &Config{
+				RedisAddr:         "localhost:6379",
+				RedisPoolSize:     50,
+				RedisMinIdleConns: 5,
+			},
+			want: poolRedisConnOpt{
+				RedisConnOpt: asynq.RedisClientOpt{
+					Addr:     "localhost:6379",
+					PoolSize: 50,
+				},
+				poolSize:     50,
+				minIdleConns: 5,
+			},
+		},
+		{
+			desc: "With cluster nodes and pool size",
+			cfg: &Config{
+				RedisClusterNodes: "localhost:5000,localhost:5001",
+				RedisPoolSize:     50,
+			},
+			want: poolRedisConnOpt{
+				RedisConnOpt: asynq.RedisClusterClientOpt{
+					Addrs: []string{"localhost:5000", "localhost:5001"},
+				},
+				poolSize: 50,
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -128,10 +256,209 @@ func TestMakeRedisConnOpt(t *testing.T) {
 				t.Fatalf("makeRedisConnOpt returned error: %v", err)
 			}
 
-			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreUnexported(tls.Config{})); diff != "" {
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreUnexported(tls.Config{}), cmp.AllowUnexported(poolRedisConnOpt{})); diff != "" {
 				t.Errorf("diff found: want=%v, got=%v; (-want,+got)\n%s",
 					tc.want, got, diff)
 			}
 		})
 	}
 }
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		cfg         *Config
+		wantNetwork string
+		wantAddr    string
+	}{
+		{&Config{Port: 8080}, "tcp", ":8080"},
+		{&Config{Port: 8080, Addr: "localhost:3000"}, "tcp", "localhost:3000"},
+		{&Config{Port: 8080, Addr: "unix:/var/run/asynqmon.sock"}, "unix", "/var/run/asynqmon.sock"},
+	}
+
+	for _, tc := range tests {
+		network, addr := listenAddr(tc.cfg)
+		if network != tc.wantNetwork || addr != tc.wantAddr {
+			t.Errorf("listenAddr(%+v) = (%q, %q), want (%q, %q)", tc.cfg, network, addr, tc.wantNetwork, tc.wantAddr)
+		}
+	}
+}
+
+func TestPprofListenAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{addr: ":6060", want: "127.0.0.1:6060"},
+		{addr: "localhost:6060", want: "localhost:6060"},
+		{addr: "[::1]:6060", want: "[::1]:6060"},
+		{addr: "0.0.0.0:6060", wantErr: true},
+		{addr: "10.0.0.1:6060", wantErr: true},
+		{addr: "6060", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := pprofListenAddr(tc.addr)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("pprofListenAddr(%q) returned error %v, want error %t", tc.addr, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("pprofListenAddr(%q) = %q, want %q", tc.addr, got, tc.want)
+		}
+	}
+}
+
+func TestParsePayloadRedactions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string][]string
+	}{
+		{in: "", want: nil},
+		{
+			in:   "email:send=to",
+			want: map[string][]string{"email:send": {"to"}},
+		},
+		{
+			in: "email:send=to, user.ssn;payment:charge=card.number;",
+			want: map[string][]string{
+				"email:send":     {"to", "user.ssn"},
+				"payment:charge": {"card.number"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parsePayloadRedactions(tc.in)
+			if err != nil {
+				t.Fatalf("parsePayloadRedactions returned error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parsePayloadRedactions(%q) = %v, want %v; (-want,+got)\n%s", tc.in, got, tc.want, diff)
+			}
+		})
+	}
+
+	for _, in := range []string{"email:send", "=to", "email:send="} {
+		if _, err := parsePayloadRedactions(in); err == nil {
+			t.Errorf("parsePayloadRedactions(%q) returned nil error, want non-nil", in)
+		}
+	}
+}
+
+func TestParseQueuePageSizes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]asynqmon.PageSizes
+	}{
+		{in: "", want: nil},
+		{
+			in:   "low=100",
+			want: map[string]asynqmon.PageSizes{"low": {Default: 100}},
+		},
+		{
+			in: "critical=10:50, low=100,",
+			want: map[string]asynqmon.PageSizes{
+				"critical": {Default: 10, Max: 50},
+				"low":      {Default: 100},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseQueuePageSizes(tc.in)
+			if err != nil {
+				t.Fatalf("parseQueuePageSizes returned error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseQueuePageSizes(%q) = %v, want %v; (-want,+got)\n%s", tc.in, got, tc.want, diff)
+			}
+		})
+	}
+
+	for _, in := range []string{"low", "=100", "low=", "low=0", "low=abc", "low=100:50", "low=10:x"} {
+		if _, err := parseQueuePageSizes(in); err == nil {
+			t.Errorf("parseQueuePageSizes(%q) returned nil error, want non-nil", in)
+		}
+	}
+}
+
+func TestParseAggregateClusters(t *testing.T) {
+	got, err := parseAggregateClusters("eu=redis://eu-redis:6379, us=redis://:pass@us-redis:6380/1,")
+	if err != nil {
+		t.Fatalf("parseAggregateClusters returned error: %v", err)
+	}
+	want := map[string]asynq.RedisConnOpt{
+		"eu": asynq.RedisClientOpt{Addr: "eu-redis:6379"},
+		"us": asynq.RedisClientOpt{Addr: "us-redis:6380", Password: "pass", DB: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseAggregateClusters returned %v, want %v; (-want,+got)\n%s", got, want, diff)
+	}
+
+	for _, in := range []string{"eu", "=redis://eu-redis:6379", "eu=", "eu=http://eu-redis:6379", "eu=redis://a:6379,eu=redis://b:6379"} {
+		if _, err := parseAggregateClusters(in); err == nil {
+			t.Errorf("parseAggregateClusters(%q) returned nil error, want non-nil", in)
+		}
+	}
+}
+
+func TestSchemaValidator(t *testing.T) {
+	v, err := compilePayloadSchemas(map[string]json.RawMessage{
+		"email:send": json.RawMessage(`{"type": "object", "required": ["to"], "properties": {"to": {"type": "string"}}}`),
+	})
+	if err != nil {
+		t.Fatalf("compilePayloadSchemas returned error: %v", err)
+	}
+
+	tests := []struct {
+		desc     string
+		taskType string
+		payload  string
+		wantErrs []string // nil if payload is valid
+	}{
+		{
+			desc:     "Valid payload",
+			taskType: "email:send",
+			payload:  `{"to": "user@example.com"}`,
+		},
+		{
+			desc:     "Task type without schema",
+			taskType: "image:resize",
+			payload:  `not json`,
+		},
+		{
+			desc:     "Missing required property",
+			taskType: "email:send",
+			payload:  `{}`,
+			wantErrs: []string{`/: missing properties: 'to'`},
+		},
+		{
+			desc:     "Invalid property type",
+			taskType: "email:send",
+			payload:  `{"to": 1}`,
+			wantErrs: []string{`/to: expected string, but got number`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := v.ValidatePayload(tc.taskType, []byte(tc.payload))
+			if tc.wantErrs == nil {
+				if err != nil {
+					t.Errorf("ValidatePayload returned error: %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*asynqmon.PayloadValidationError)
+			if !ok {
+				t.Fatalf("ValidatePayload returned %v, want *asynqmon.PayloadValidationError", err)
+			}
+			if diff := cmp.Diff(tc.wantErrs, verr.Errors); diff != "" {
+				t.Errorf("ValidatePayload returned errors %v, want %v; (-want,+got)\n%s", verr.Errors, tc.wantErrs, diff)
+			}
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
+
+	"github.com/hibiken/asynqmon"
 )
 
 // A responseRecorderWriter records response status and size.
@@ -35,26 +38,162 @@ func (w *responseRecorderWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-func loggingMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rw := &responseRecorderWriter{ResponseWriter: w}
-		h.ServeHTTP(rw, r)
-
-		host, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			host = r.RemoteAddr
-		}
-		username := "-"
-		if user := r.URL.User; user != nil {
-			username = user.Username()
-		}
-		size := "-"
-		if rw.size > 0 {
-			size = strconv.Itoa(rw.size)
-		}
-		// Write a log in Apache common log format (http://httpd.apache.org/docs/2.2/logs.html#common).
-		fmt.Fprintf(os.Stdout, "%s - %s [%s] \"%s %s %s\" %d %s\n",
-			host, username, time.Now().Format("02/Jan/2006:15:04:05 -0700"),
-			r.Method, r.URL, r.Proto, rw.status, size)
-	})
+// Flush forwards to the underlying writer so that queue exports and NDJSON streams
+// reach the client page by page instead of only when access logging completes.
+func (w *responseRecorderWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Supported values for the --log-format flag.
+const (
+	// Apache common log format (http://httpd.apache.org/docs/2.2/logs.html#common).
+	logFormatText = "text"
+	// Apache combined log format (http://httpd.apache.org/docs/2.2/logs.html#combined).
+	logFormatApache = "apache"
+	// One JSON object per line.
+	logFormatJSON = "json"
+)
+
+// accessLogEntry holds the data written for each request by the logging middleware.
+type accessLogEntry struct {
+	RequestID string        `json:"request_id"`
+	Host      string        `json:"remote_addr"`
+	Username  string        `json:"user"`
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	URI       string        `json:"uri"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Size      int           `json:"size"`
+	Referer   string        `json:"referer"`
+	UserAgent string        `json:"user_agent"`
+	Duration  time.Duration `json:"duration_ns"`
+}
+
+func newAccessLogEntry(r *http.Request, rw *responseRecorderWriter, start time.Time) *accessLogEntry {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	username := "-"
+	if user := r.URL.User; user != nil {
+		username = user.Username()
+	}
+	status := rw.status
+	if status == 0 {
+		// Handler wrote neither header nor body, net/http responds with 200.
+		status = http.StatusOK
+	}
+	return &accessLogEntry{
+		RequestID: asynqmon.RequestIDFromContext(r.Context()),
+		Host:      host,
+		Username:  username,
+		Time:      start,
+		Method:    r.Method,
+		URI:       r.URL.RequestURI(),
+		Proto:     r.Proto,
+		Status:    status,
+		Size:      rw.size,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		Duration:  time.Since(start),
+	}
+}
+
+// writeCommonLog writes a log in Apache common log format.
+func writeCommonLog(out io.Writer, e *accessLogEntry) {
+	fmt.Fprintf(out, "%s - %s [%s] \"%s %s %s\" %d %s\n",
+		e.Host, e.Username, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.URI, e.Proto, e.Status, sizeString(e.Size))
+}
+
+// writeCombinedLog writes a log in Apache combined log format.
+func writeCombinedLog(out io.Writer, e *accessLogEntry) {
+	fmt.Fprintf(out, "%s - %s [%s] \"%s %s %s\" %d %s %s %s\n",
+		e.Host, e.Username, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.URI, e.Proto, e.Status, sizeString(e.Size),
+		strconv.Quote(dashIfEmpty(e.Referer)), strconv.Quote(dashIfEmpty(e.UserAgent)))
+}
+
+// writeJSONLog writes a log as a JSON object.
+func writeJSONLog(out io.Writer, e *accessLogEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(out, "error: could not encode access log: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "%s\n", b)
+}
+
+// sizeString returns the response size as printed in Apache logs.
+func sizeString(size int) string {
+	if size == 0 {
+		return "-"
+	}
+	return strconv.Itoa(size)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// newLoggingMiddleware returns a middleware which writes an access log to out for each request
+// in the given format.
+// The middleware should be wrapped by asynqmon.RequestIDMiddleware to include request IDs in JSON logs.
+func newLoggingMiddleware(format string, out io.Writer) (func(http.Handler) http.Handler, error) {
+	var write func(io.Writer, *accessLogEntry)
+	switch format {
+	case logFormatText:
+		write = writeCommonLog
+	case logFormatApache:
+		write = writeCombinedLog
+	case logFormatJSON:
+		write = writeJSONLog
+	default:
+		return nil, fmt.Errorf("unsupported log format %q: want one of %q, %q, %q",
+			format, logFormatText, logFormatApache, logFormatJSON)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseRecorderWriter{ResponseWriter: w}
+			h.ServeHTTP(rw, r)
+			write(out, newAccessLogEntry(r, rw, start))
+		})
+	}, nil
+}
+
+// Middleware wraps an http.Handler to add behavior such as authentication.
+type Middleware func(http.Handler) http.Handler
+
+// pluginMiddlewares holds the middlewares registered with AppendMiddleware, in registration order.
+var pluginMiddlewares []Middleware
+
+// AppendMiddleware registers middlewares to wrap every handler served on the main listener,
+// including /metrics and /debug/pprof/. The separate --pprof-addr listener is not wrapped.
+//
+// It is meant to be called from an init function in a file added to this package,
+// typically guarded by a build tag (e.g. "//go:build oidc" and "// +build oidc"), so that custom
+// behavior can be compiled in without modifying main.
+// AppendMiddleware must not be called after the server has started.
+//
+// Middlewares are applied in the order they are registered: the first registered
+// middleware is the outermost of the plugins. All plugins run after request ID
+// assignment, access logging and CORS handling, so that every request is logged
+// and CORS preflight requests are answered without reaching the plugins.
+func AppendMiddleware(mws ...Middleware) {
+	pluginMiddlewares = append(pluginMiddlewares, mws...)
+}
+
+// chain wraps h with mws so that mws[0] is the outermost middleware.
+func chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
 }
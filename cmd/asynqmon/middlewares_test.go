@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hibiken/asynqmon"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	tests := []struct {
+		format string
+		want   *regexp.Regexp
+	}{
+		{
+			format: logFormatText,
+			want:   regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /api/queues\?page=2 HTTP/1\.1" 201 5\n$`),
+		},
+		{
+			format: logFormatApache,
+			want:   regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /api/queues\?page=2 HTTP/1\.1" 201 5 "http://example\.com/" "test-agent"\n$`),
+		},
+		{
+			format: logFormatJSON,
+			want:   regexp.MustCompile(`^\{"request_id":"","remote_addr":"192\.0\.2\.1","user":"-","time":"[^"]+","method":"GET","uri":"/api/queues\?page=2","proto":"HTTP/1\.1","status":201,"size":5,"referer":"http://example\.com/","user_agent":"test-agent","duration_ns":\d+\}\n$`),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logging, err := newLoggingMiddleware(tc.format, &buf)
+			if err != nil {
+				t.Fatalf("newLoggingMiddleware returned error: %v", err)
+			}
+			h := logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("hello"))
+			}))
+			req := httptest.NewRequest("GET", "/api/queues?page=2", nil)
+			req.Header.Set("Referer", "http://example.com/")
+			req.Header.Set("User-Agent", "test-agent")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got := buf.String(); !tc.want.MatchString(got) {
+				t.Errorf("logged %q, want match with %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := newLoggingMiddleware("xml", &bytes.Buffer{}); err == nil {
+		t.Errorf("newLoggingMiddleware(%q) returned nil error, want non-nil", "xml")
+	}
+}
+
+func TestLoggingMiddlewareWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logging, err := newLoggingMiddleware(logFormatJSON, &buf)
+	if err != nil {
+		t.Fatalf("newLoggingMiddleware returned error: %v", err)
+	}
+	h := asynqmon.RequestIDMiddleware(logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest("GET", "/api/queues", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("X-Request-ID response header = %q, want %q", got, "abc-123")
+	}
+	if got := buf.String(); !strings.Contains(got, `"request_id":"abc-123"`) {
+		t.Errorf("logged %q, want request_id %q", got, "abc-123")
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var got []string
+	mw := func(name string) Middleware {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = append(got, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, "handler")
+	})
+
+	chain(h, mw("first"), mw("second"), mw("third")).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := "first,second,third,handler"
+	if s := strings.Join(got, ","); s != want {
+		t.Errorf("middlewares ran in order %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hibiken/asynqmon"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaValidator validates task payloads against JSON schemas registered by task type.
+// Payloads of task types without a registered schema are not validated.
+type schemaValidator struct {
+	schemas map[string]*jsonschema.Schema // keyed by task type
+}
+
+// loadPayloadSchemas reads a JSON file which maps task types to JSON schemas,
+// and returns a PayloadValidator using the schemas.
+//
+// Example file content:
+//
+//	{
+//	  "email:send": {"type": "object", "required": ["to"], "properties": {"to": {"type": "string"}}}
+//	}
+func loadPayloadSchemas(path string) (*schemaValidator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse payload schemas file %q: %v", path, err)
+	}
+	return compilePayloadSchemas(raw)
+}
+
+func compilePayloadSchemas(raw map[string]json.RawMessage) (*schemaValidator, error) {
+	// Compile in sorted order so that errors are reported deterministically.
+	taskTypes := make([]string, 0, len(raw))
+	for t := range raw {
+		taskTypes = append(taskTypes, t)
+	}
+	sort.Strings(taskTypes)
+
+	v := &schemaValidator{schemas: make(map[string]*jsonschema.Schema)}
+	for _, t := range taskTypes {
+		c := jsonschema.NewCompiler()
+		url := "schema:///" + t
+		if err := c.AddResource(url, bytes.NewReader(raw[t])); err != nil {
+			return nil, fmt.Errorf("invalid payload schema for task type %q: %v", t, err)
+		}
+		schema, err := c.Compile(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload schema for task type %q: %v", t, err)
+		}
+		v.schemas[t] = schema
+	}
+	return v, nil
+}
+
+func (v *schemaValidator) ValidatePayload(taskType string, payload []byte) error {
+	schema, ok := v.schemas[taskType]
+	if !ok {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return &asynqmon.PayloadValidationError{
+			TaskType: taskType,
+			Errors:   []string{fmt.Sprintf("payload is not valid JSON: %v", err)},
+		}
+	}
+	err := schema.Validate(val)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	return &asynqmon.PayloadValidationError{
+		TaskType: taskType,
+		Errors:   collectValidationErrors(verr, nil),
+	}
+}
+
+// collectValidationErrors appends the messages of the leaf errors in ve to msgs.
+func collectValidationErrors(ve *jsonschema.ValidationError, msgs []string) []string {
+	if len(ve.Causes) == 0 {
+		loc := ve.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		return append(msgs, fmt.Sprintf("%s: %s", loc, ve.Message))
+	}
+	for _, c := range ve.Causes {
+		msgs = collectValidationErrors(c, msgs)
+	}
+	return msgs
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Duration of CPU profiles when the seconds query param is not set.
+// pprof rejects profiles which do not finish within the write timeout of the server.
+const defaultProfileDuration = 30 * time.Second
+
+// newPprofHandler returns a handler which serves runtime profiling data under /debug/pprof/.
+func newPprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// pprofListenAddr returns the address to serve pprof on given the value of --pprof-addr.
+// The pprof listener is only allowed to bind to a loopback address; if the host is omitted
+// (e.g. ":6060"), it binds to 127.0.0.1.
+func pprofListenAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid pprof address %q: %v", addr, err)
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	if host == "localhost" {
+		return addr, nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return "", fmt.Errorf("invalid pprof address %q: host must be a loopback address", addr)
+	}
+	return addr, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Minimum and maximum delay between attempts to connect to redis at startup.
+const (
+	redisStartupMinBackoff = 100 * time.Millisecond
+	redisStartupMaxBackoff = 5 * time.Second
+)
+
+// waitForRedis calls ping until it succeeds, doubling the delay between attempts.
+// It returns an error if ping has not succeeded within timeout.
+//
+// This only delays serving until redis is reachable; once connected, the redis clients
+// reconnect on their own if the connection is lost later.
+func waitForRedis(ping func(context.Context) error, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	backoff := redisStartupMinBackoff
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("connected to redis after %d attempts", attempt)
+			}
+			return nil
+		}
+		log.Printf("could not connect to redis (attempt %d): %v; retrying in %v", attempt, err, backoff)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("could not connect to redis within %v: %v", timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > redisStartupMaxBackoff {
+			backoff = redisStartupMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForRedis(t *testing.T) {
+	errUnavailable := errors.New("connection refused")
+
+	calls := 0
+	err := waitForRedis(func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errUnavailable
+		}
+		return nil
+	}, 5*time.Second)
+	if err != nil {
+		t.Errorf("waitForRedis returned error %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("waitForRedis called ping %d times, want 3", calls)
+	}
+
+	err = waitForRedis(func(context.Context) error { return errUnavailable }, 250*time.Millisecond)
+	if err == nil {
+		t.Error("waitForRedis returned nil when redis is unavailable, want error")
+	}
+}
@@ -169,6 +169,10 @@ type taskInfo struct {
 	Type string `json:"type"`
 	// Payload is the payload data of the task.
 	Payload string `json:"payload"`
+	// PayloadTruncated is true if Payload is truncated for display.
+	PayloadTruncated bool `json:"payload_truncated"`
+	// PayloadSize is the size of the original payload in bytes.
+	PayloadSize int `json:"payload_size"`
 	// State indicates the task state.
 	State string `json:"state"`
 	// MaxRetry is the maximum number of times the task can be retried.
@@ -215,33 +219,60 @@ func formatTimeInRFC3339(t time.Time) string {
 }
 
 func toTaskInfo(info *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter) *taskInfo {
+	payload, truncated := formatPayload(pf, info.Type, info.Payload)
 	return &taskInfo{
-		ID:            info.ID,
-		Queue:         info.Queue,
-		Type:          info.Type,
-		Payload:       pf.FormatPayload(info.Type, info.Payload),
-		State:         info.State.String(),
-		MaxRetry:      info.MaxRetry,
-		Retried:       info.Retried,
-		LastErr:       info.LastErr,
-		LastFailedAt:  formatTimeInRFC3339(info.LastFailedAt),
-		Timeout:       int(info.Timeout.Seconds()),
-		Deadline:      formatTimeInRFC3339(info.Deadline),
-		NextProcessAt: formatTimeInRFC3339(info.NextProcessAt),
-		CompletedAt:   formatTimeInRFC3339(info.CompletedAt),
-		Result:        rf.FormatResult("", info.Result),
-		TTL:           int64(taskTTL(info).Seconds()),
+		ID:               info.ID,
+		Queue:            info.Queue,
+		Type:             info.Type,
+		Payload:          payload,
+		PayloadTruncated: truncated,
+		PayloadSize:      len(info.Payload),
+		State:            info.State.String(),
+		MaxRetry:         info.MaxRetry,
+		Retried:          info.Retried,
+		LastErr:          info.LastErr,
+		LastFailedAt:     formatTimeInRFC3339(info.LastFailedAt),
+		Timeout:          int(info.Timeout.Seconds()),
+		Deadline:         formatTimeInRFC3339(info.Deadline),
+		NextProcessAt:    formatTimeInRFC3339(info.NextProcessAt),
+		CompletedAt:      formatTimeInRFC3339(info.CompletedAt),
+		Result:           rf.FormatResult("", info.Result),
+		TTL:              int64(taskTTL(info).Seconds()),
 	}
 }
 
 type baseTask struct {
-	ID        string `json:"id"`
-	Type      string `json:"type"`
-	Payload   string `json:"payload"`
-	Queue     string `json:"queue"`
-	MaxRetry  int    `json:"max_retry"`
-	Retried   int    `json:"retried"`
-	LastError string `json:"error_message"`
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+	// PayloadTruncated is true if Payload is truncated for display.
+	PayloadTruncated bool `json:"payload_truncated"`
+	// PayloadSize is the size of the original payload in bytes.
+	PayloadSize int    `json:"payload_size"`
+	Queue       string `json:"queue"`
+	MaxRetry    int    `json:"max_retry"`
+	Retried     int    `json:"retried"`
+	LastError   string `json:"error_message"`
+	// Annotations set via the annotation endpoints; omitted if the task has none.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// base returns t; it is promoted to the task types embedding *baseTask.
+func (t *baseTask) base() *baseTask { return t }
+
+func toBaseTask(ti *asynq.TaskInfo, pf PayloadFormatter) *baseTask {
+	payload, truncated := formatPayload(pf, ti.Type, ti.Payload)
+	return &baseTask{
+		ID:               ti.ID,
+		Type:             ti.Type,
+		Payload:          payload,
+		PayloadTruncated: truncated,
+		PayloadSize:      len(ti.Payload),
+		Queue:            ti.Queue,
+		MaxRetry:         ti.MaxRetry,
+		Retried:          ti.Retried,
+		LastError:        ti.LastErr,
+	}
 }
 
 type activeTask struct {
@@ -262,18 +293,21 @@ type activeTask struct {
 
 	// IsOrphaned indicates whether the task is left in active state with no worker processing it.
 	IsOrphaned bool `json:"is_orphaned"`
+
+	// LeaseExpiration indicates the time the lease of the worker processing the task expires.
+	// Workers extend the lease while they are alive.
+	//
+	// Value is either time formatted in RFC3339 format, or "-" which indicates that
+	// the task has no lease.
+	LeaseExpiration string `json:"lease_expiration"`
+
+	// LeaseExpired indicates whether the lease has expired, which means that the worker
+	// processing the task is likely dead.
+	LeaseExpired bool `json:"lease_expired"`
 }
 
 func toActiveTask(ti *asynq.TaskInfo, pf PayloadFormatter) *activeTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &activeTask{baseTask: base, IsOrphaned: ti.IsOrphaned}
 }
 
@@ -291,15 +325,7 @@ type pendingTask struct {
 }
 
 func toPendingTask(ti *asynq.TaskInfo, pf PayloadFormatter) *pendingTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &pendingTask{
 		baseTask: base,
 	}
@@ -319,15 +345,7 @@ type aggregatingTask struct {
 }
 
 func toAggregatingTask(ti *asynq.TaskInfo, pf PayloadFormatter) *aggregatingTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &aggregatingTask{
 		baseTask: base,
 		Group:    ti.Group,
@@ -348,15 +366,7 @@ type scheduledTask struct {
 }
 
 func toScheduledTask(ti *asynq.TaskInfo, pf PayloadFormatter) *scheduledTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &scheduledTask{
 		baseTask:      base,
 		NextProcessAt: ti.NextProcessAt,
@@ -377,15 +387,7 @@ type retryTask struct {
 }
 
 func toRetryTask(ti *asynq.TaskInfo, pf PayloadFormatter) *retryTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &retryTask{
 		baseTask:      base,
 		NextProcessAt: ti.NextProcessAt,
@@ -406,15 +408,7 @@ type archivedTask struct {
 }
 
 func toArchivedTask(ti *asynq.TaskInfo, pf PayloadFormatter) *archivedTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &archivedTask{
 		baseTask:     base,
 		LastFailedAt: ti.LastFailedAt,
@@ -438,15 +432,7 @@ type completedTask struct {
 }
 
 func toCompletedTask(ti *asynq.TaskInfo, pf PayloadFormatter, rf ResultFormatter) *completedTask {
-	base := &baseTask{
-		ID:        ti.ID,
-		Type:      ti.Type,
-		Payload:   pf.FormatPayload(ti.Type, ti.Payload),
-		Queue:     ti.Queue,
-		MaxRetry:  ti.MaxRetry,
-		Retried:   ti.Retried,
-		LastError: ti.LastErr,
-	}
+	base := toBaseTask(ti, pf)
 	return &completedTask{
 		baseTask:    base,
 		CompletedAt: ti.CompletedAt,
@@ -488,6 +474,7 @@ type schedulerEntry struct {
 	Spec          string   `json:"spec"`
 	TaskType      string   `json:"task_type"`
 	TaskPayload   string   `json:"task_payload"`
+	Queue         string   `json:"queue"`
 	Opts          []string `json:"options"`
 	NextEnqueueAt string   `json:"next_enqueue_at"`
 	// This field is omitted if there were no previous enqueue events.
@@ -496,8 +483,12 @@ type schedulerEntry struct {
 
 func toSchedulerEntry(e *asynq.SchedulerEntry, pf PayloadFormatter) *schedulerEntry {
 	opts := make([]string, 0) // create a non-nil, empty slice to avoid null in json output
+	qname := "default"        // tasks are enqueued to the default queue unless specified
 	for _, o := range e.Opts {
 		opts = append(opts, o.String())
+		if o.Type() == asynq.QueueOpt {
+			qname = o.Value().(string)
+		}
 	}
 	prev := ""
 	if !e.Prev.IsZero() {
@@ -508,6 +499,7 @@ func toSchedulerEntry(e *asynq.SchedulerEntry, pf PayloadFormatter) *schedulerEn
 		Spec:          e.Spec,
 		TaskType:      e.Task.Type(),
 		TaskPayload:   pf.FormatPayload(e.Task.Type(), e.Task.Payload()),
+		Queue:         qname,
 		Opts:          opts,
 		NextEnqueueAt: e.Next.Format(time.RFC3339),
 		PrevEnqueueAt: prev,
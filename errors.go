@@ -0,0 +1,117 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to write error responses
+// ****************************************************************************
+
+// Error codes included in error responses.
+const (
+	errCodeInvalidArgument  = "invalid_argument"
+	errCodeNotFound         = "not_found"
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeConflict         = "conflict"
+	errCodeUnprocessable    = "unprocessable"
+	errCodeInvalidPayload   = "invalid_payload"
+	errCodeInternal         = "internal"
+	errCodeUnavailable      = "unavailable"
+)
+
+// errorResponse is the body of error responses: {"error":{"code":...,"message":...}}.
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	// Machine-readable error code (e.g. "not_found").
+	Code string `json:"code"`
+	// Human-readable description of the error.
+	Message string `json:"message"`
+	// Individual problems which caused the error, if any (e.g. payload validation errors).
+	Details []string `json:"details,omitempty"`
+}
+
+// respondError writes an error response with the given status code and JSON error body.
+func respondError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorResponse(w, status, errorDetail{Code: code, Message: message})
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, detail errorDetail) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: detail})
+}
+
+// errorCodeForStatus returns the error code for an HTTP status code.
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return errCodeInvalidArgument
+	case http.StatusNotFound:
+		return errCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return errCodeMethodNotAllowed
+	case http.StatusConflict:
+		return errCodeConflict
+	case http.StatusUnprocessableEntity:
+		return errCodeUnprocessable
+	case http.StatusServiceUnavailable:
+		return errCodeUnavailable
+	}
+	return errCodeInternal
+}
+
+// Error messages which indicate a connection-level failure.
+// These are checked as a fallback, since asynq does not always wrap the underlying error.
+var connErrorMessages = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"i/o timeout",
+	"no such host",
+	"network is unreachable",
+	"connection pool timeout", // go-redis pool.ErrPoolTimeout
+	"client is closed",        // go-redis redis.ErrClosed
+}
+
+// isConnectionError reports whether err is caused by a failure to communicate with redis
+// (e.g. dial failures, timeouts), as opposed to an error returned by redis itself.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.EOF) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range connErrorMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeInternalServerError writes the error response for an unexpected error.
+//
+// If err is a connection-level error, it responds with 503 Service Unavailable and
+// a generic message to avoid exposing the details of the backend. The detailed error is logged.
+func writeInternalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	if isConnectionError(err) {
+		logRequestf(r, "error: redis unavailable: %v", err)
+		respondError(w, http.StatusServiceUnavailable, errCodeUnavailable, "backend unavailable")
+		return
+	}
+	respondError(w, http.StatusInternalServerError, errCodeInternal, strings.TrimPrefix(err.Error(), "asynq: "))
+}
@@ -0,0 +1,63 @@
+package asynqmon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("asynq: %v", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{errors.New("redis: connection pool timeout"), true},
+		{errors.New("asynq: queue \"foo\" does not exist"), false},
+		{errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+	}
+
+	for _, tc := range tests {
+		if got := isConnectionError(tc.err); got != tc.want {
+			t.Errorf("isConnectionError(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestWriteInternalServerError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			err:        fmt.Errorf("asynq: %v", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"error":{"code":"unavailable","message":"backend unavailable"}}` + "\n",
+		},
+		{
+			err:        errors.New("asynq: queue \"foo\" does not exist"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"error":{"code":"internal","message":"queue \"foo\" does not exist"}}` + "\n",
+		},
+	}
+
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		writeInternalServerError(w, httptest.NewRequest("GET", "/api/queues", nil), tc.err)
+		if w.Code != tc.wantStatus {
+			t.Errorf("writeInternalServerError(%v) wrote status %d, want %d", tc.err, w.Code, tc.wantStatus)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("writeInternalServerError(%v) wrote Content-Type %q, want %q", tc.err, got, "application/json")
+		}
+		if got := w.Body.String(); got != tc.wantBody {
+			t.Errorf("writeInternalServerError(%v) wrote body %q, want %q", tc.err, got, tc.wantBody)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package asynqmon
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to support conditional GET requests using ETag
+// ****************************************************************************
+
+// computeETag returns a weak ETag computed from the JSON serialization of the given values.
+// encoding/json sorts map keys when marshaling, so the ETag is stable for identical data.
+func computeETag(vals ...interface{}) (string, error) {
+	b, err := json.Marshal(vals)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(b)
+	return `W/"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
+
+// etagMatch reports whether the If-None-Match header value in the request
+// matches the given ETag.
+func etagMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		// Weak comparison is used, so ignore the weak indicator.
+		if v == "*" || strings.TrimPrefix(v, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResponseJSONWithETag sets the ETag header computed from etagSrc and writes resp as JSON.
+// If the request has a matching If-None-Match header, it responds with 304 Not Modified instead.
+func writeResponseJSONWithETag(w http.ResponseWriter, r *http.Request, resp interface{}, etagSrc ...interface{}) {
+	etag, err := computeETag(etagSrc...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if etagMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeResponseJSON(w, resp)
+}
+
+// snapshotForETag returns a copy of s without the timestamp, which changes on every request.
+func snapshotForETag(s *queueStateSnapshot) *queueStateSnapshot {
+	c := *s
+	c.Timestamp = time.Time{}
+	return &c
+}
@@ -0,0 +1,140 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for task event related endpoints
+// ****************************************************************************
+
+// Default and maximum number of events returned by the events endpoint.
+const (
+	defaultEventsLimit = 20
+	maxEventsLimit     = 100
+)
+
+type taskEvent struct {
+	TaskID string `json:"task_id"`
+	Type   string `json:"task_type"`
+	Queue  string `json:"queue"`
+	// State of the task: "completed" for succeeded tasks, "archived" for failed tasks.
+	State string `json:"state"`
+	// Time the task completed or failed.
+	Timestamp time.Time `json:"timestamp"`
+	// Error message of the failure. Empty for completed tasks.
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+type listEventsResponse struct {
+	Events []*taskEvent `json:"events"`
+}
+
+// newListEventsHandlerFunc returns a handler which lists recently completed and archived tasks
+// across all queues, newest first.
+//
+// asynq does not keep a history of processed tasks, so events are built from the completed and
+// archived tasks still retained in redis. Completed tasks are only retained if they were enqueued
+// with a retention period, and tasks failed with remaining retries are not included.
+//
+// Optional query params:
+// `limit`: maximum number of events to return (default 20, max 100)
+func newListEventsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		limit := defaultEventsLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for limit: %q", s))
+				return
+			}
+			limit = n
+		}
+		if limit > maxEventsLimit {
+			limit = maxEventsLimit
+		}
+
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		events := make([]*taskEvent, 0)
+		for _, qname := range qnames {
+			qinfo, err := inspector.GetQueueInfo(qname)
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			completed, err := listNewestTasks(inspector.ListCompletedTasks, qname, qinfo.Completed, limit)
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			for _, t := range completed {
+				events = append(events, &taskEvent{
+					TaskID:    t.ID,
+					Type:      t.Type,
+					Queue:     t.Queue,
+					State:     t.State.String(),
+					Timestamp: t.CompletedAt,
+				})
+			}
+			archived, err := listNewestTasks(inspector.ListArchivedTasks, qname, qinfo.Archived, limit)
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			for _, t := range archived {
+				events = append(events, &taskEvent{
+					TaskID:       t.ID,
+					Type:         t.Type,
+					Queue:        t.Queue,
+					State:        t.State.String(),
+					Timestamp:    t.LastFailedAt,
+					ErrorMessage: t.LastErr,
+				})
+			}
+		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.After(events[j].Timestamp)
+		})
+		if len(events) > limit {
+			events = events[:limit]
+		}
+		writeResponseJSON(w, listEventsResponse{Events: events})
+	}
+}
+
+// listNewestTasks returns at least n (if available) of the tasks at the end of the list,
+// given the total number of tasks in the list.
+//
+// Tasks in the completed and archived states are listed in the order they were added
+// (oldest first), so the newest tasks are found in the last pages.
+func listNewestTasks(list listTasksFunc, qname string, total, n int) ([]*asynq.TaskInfo, error) {
+	if total == 0 {
+		return nil, nil
+	}
+	lastPage := (total + n - 1) / n
+	var tasks []*asynq.TaskInfo
+	// The last page may have less than n tasks, so read the page before it as well.
+	for page := lastPage - 1; page <= lastPage; page++ {
+		if page < 1 {
+			continue
+		}
+		res, err := list(qname, asynq.Page(page), asynq.PageSize(n))
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, res...)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,141 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to project task list responses to the fields named by the `fields` query param
+// ****************************************************************************
+
+// stateField is the name of the projectable field holding the state of the listed tasks.
+// Unprojected responses omit it since the state is implied by the endpoint.
+const stateField = "state"
+
+// fieldProjection projects task objects to the fields selected by the `fields` query param.
+type fieldProjection struct {
+	selected map[string]bool
+	// State of the projected tasks.
+	state string
+}
+
+// parseFieldProjection parses the `fields` query param (e.g. "id,type,state") for tasks of type t,
+// a pointer to a task struct, listed in the given state.
+// Fields are named by their JSON names, so the names match the ones in unprojected responses,
+// and "state" names the state of the tasks.
+// Names which are not fields of the task type are returned in ignored.
+// If the param is not set, the returned projection is nil.
+func parseFieldProjection(r *http.Request, t reflect.Type, state string) (p *fieldProjection, ignored []string) {
+	s := r.URL.Query().Get("fields")
+	if s == "" {
+		return nil, nil
+	}
+	known := map[string]bool{stateField: true}
+	for _, name := range jsonFieldNames(t) {
+		known[name] = true
+	}
+	p = &fieldProjection{selected: make(map[string]bool), state: state}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case known[name]:
+			p.selected[name] = true
+		default:
+			ignored = append(ignored, name)
+		}
+	}
+	return p, ignored
+}
+
+// project returns the selected fields of task.
+func (p *fieldProjection) project(task interface{}) (map[string]json.RawMessage, error) {
+	// Round trip through JSON so that fields are projected exactly as they are encoded.
+	b, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	for name := range obj {
+		if !p.selected[name] {
+			delete(obj, name)
+		}
+	}
+	if _, ok := obj[stateField]; !ok && p.selected[stateField] {
+		if obj[stateField], err = json.Marshal(p.state); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// projectTasks projects tasks, a slice of pointers to task structs listed in the given state,
+// as described by parseFieldProjection.
+// If the `fields` query param is not set, tasks are returned as is.
+func projectTasks(r *http.Request, tasks interface{}, state string) (projected interface{}, ignored []string, err error) {
+	v := reflect.ValueOf(tasks)
+	p, ignored := parseFieldProjection(r, v.Type().Elem(), state)
+	if p == nil {
+		return tasks, nil, nil
+	}
+	objs := make([]map[string]json.RawMessage, v.Len())
+	for i := range objs {
+		if objs[i], err = p.project(v.Index(i).Interface()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return objs, ignored, nil
+}
+
+// applyFieldProjection projects the tasks in payload with projectTasks,
+// and lists the ignored field names in payload if any.
+func applyFieldProjection(r *http.Request, payload map[string]interface{}, state string) error {
+	projected, ignored, err := projectTasks(r, payload["tasks"], state)
+	if err != nil {
+		return err
+	}
+	payload["tasks"] = projected
+	if len(ignored) > 0 {
+		payload["ignored_fields"] = ignored
+	}
+	return nil
+}
+
+// jsonFieldNames returns the JSON names of the fields of the struct type t (or pointer to it),
+// including the fields of embedded structs.
+func jsonFieldNames(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			names = append(names, jsonFieldNames(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
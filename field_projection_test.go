@@ -0,0 +1,71 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProjectTasks(t *testing.T) {
+	tasks := []*pendingTask{
+		{baseTask: &baseTask{ID: "a", Type: "email:send", Queue: "default"}},
+	}
+	tests := []struct {
+		fields      string
+		want        string
+		wantIgnored []string
+	}{
+		{"id,type", `[{"id":"a","type":"email:send"}]`, nil},
+		{" id , bogus,", `[{"id":"a"}]`, []string{"bogus"}},
+		{"annotations", `[{}]`, nil},
+		{"id,type,state", `[{"id":"a","state":"pending","type":"email:send"}]`, nil},
+	}
+
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/?fields="+url.QueryEscape(tc.fields), nil)
+		projected, ignored, err := projectTasks(r, tasks, "pending")
+		if err != nil {
+			t.Fatalf("projectTasks(%q) returned error: %v", tc.fields, err)
+		}
+		b, err := json.Marshal(projected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("projectTasks(%q) = %s, want %s", tc.fields, b, tc.want)
+		}
+		if diff := cmp.Diff(tc.wantIgnored, ignored); diff != "" {
+			t.Errorf("projectTasks(%q) ignored mismatch (-want,+got):\n%s", tc.fields, diff)
+		}
+	}
+}
+
+func TestProjectTasksWithoutFields(t *testing.T) {
+	tasks := make([]*pendingTask, 0)
+	projected, _, err := projectTasks(httptest.NewRequest("GET", "/", nil), tasks, "pending")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := projected.([]*pendingTask); !ok || got == nil {
+		t.Errorf("projectTasks without fields = %#v, want the tasks as is", projected)
+	}
+}
+
+func TestProjectTasksKeepsStateField(t *testing.T) {
+	tasks := []*taskInfo{{ID: "a", State: "retry"}}
+	r := httptest.NewRequest("GET", "/?fields=id,state", nil)
+	projected, _, err := projectTasks(r, tasks, "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"id":"a","state":"retry"}]`; string(b) != want {
+		t.Errorf("projectTasks = %s, want %s", b, want)
+	}
+}
@@ -15,16 +15,17 @@ type listGroupsResponse struct {
 
 func newListGroupsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 
 		groups, err := inspector.Groups(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 
@@ -33,7 +34,7 @@ func newListGroupsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 			Groups: toGroupInfos(groups),
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 	}
@@ -3,11 +3,16 @@ package asynqmon
 import (
 	"embed"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hibiken/asynq"
 )
@@ -35,14 +40,101 @@ type Options struct {
 	// This field is optional.
 	ResultFormatter ResultFormatter
 
+	// PayloadRedactions maps a task type to a list of dot-separated JSON field paths (e.g. "user.email")
+	// whose values are replaced with "***" in payloads shown in the UI.
+	// Payloads which are not JSON, or do not contain any of the fields, are shown unchanged.
+	//
+	// This field is optional.
+	PayloadRedactions map[string][]string
+
+	// MaxPayloadDisplayBytes specifies the maximum number of bytes of a formatted payload included in task responses.
+	// Longer payloads are truncated and marked with "payload_truncated", and can be downloaded in full
+	// via the payload download endpoint.
+	//
+	// This field is optional. If this field is zero, payloads are not truncated.
+	MaxPayloadDisplayBytes int
+
+	// QueuePageSizes maps a queue name to the page sizes used by the task list endpoints of the queue.
+	// Queues without an entry use the default page size of 20 with no maximum.
+	//
+	// This field is optional.
+	QueuePageSizes map[string]PageSizes
+
+	// PayloadValidator is used to validate payload of tasks enqueued via the API.
+	//
+	// This field is optional. If this field is not set, payloads are not validated.
+	PayloadValidator PayloadValidator
+
 	// PrometheusAddress specifies the address of the Prometheus to connect to.
 	//
 	// This field is optional. If this field is set, asynqmon will query the Prometheus server
 	// to get the time series data about queue metrics and show them in the web UI.
 	PrometheusAddress string
 
+	// StatsCacheInterval specifies how often the cached stats of queues are refreshed.
+	// Listing queues is served from the cache, which avoids querying redis on every request.
+	//
+	// This field is optional. If this field is zero, the stats are fetched from redis on every request.
+	StatsCacheInterval time.Duration
+
+	// MetricsRegisterer is used to register prometheus metrics about API requests,
+	// such as request latency and the number of requests by status code.
+	//
+	// This field is optional. If this field is not set, metrics about API requests are not collected.
+	MetricsRegisterer prometheus.Registerer
+
+	// IdempotencyKeyTTL specifies how long the response of a mutating request with an Idempotency-Key header
+	// is stored in redis. A request with the same key within the duration gets the stored response
+	// instead of being handled again, unless its body differs from the original request,
+	// which is rejected with 422 Unprocessable Entity.
+	//
+	// This field is optional. Default is DefaultIdempotencyKeyTTL.
+	IdempotencyKeyTTL time.Duration
+
+	// AnnotationTTL specifies how long the annotations of a task are kept after they are last updated.
+	//
+	// This field is optional. Default is DefaultAnnotationTTL.
+	AnnotationTTL time.Duration
+
+	// Set DisableServersAPI to true to not serve the /api/servers and /api/queues:priorities endpoints,
+	// which expose information about asynq servers and their workers.
+	DisableServersAPI bool
+
+	// Set DisableSchedulerAPI to true to not serve the /api/scheduler_entries endpoints,
+	// which expose information about periodic tasks registered by schedulers.
+	DisableSchedulerAPI bool
+
+	// Set EnableRedisDiagnosticsAPI to true to serve the /api/redis/slowlog and /api/redis/latency endpoints,
+	// which expose the commands run against redis, including their arguments.
+	EnableRedisDiagnosticsAPI bool
+
+	// ClusterName is the name of the cluster RedisConnOpt connects to.
+	// It labels the queues of the cluster in the aggregate endpoints.
+	//
+	// This field is optional. Default is "default".
+	ClusterName string
+
+	// AggregateClusters maps cluster names to the connection options of additional asynq clusters.
+	// Their queues are listed along with the queues of RedisConnOpt by the /api/aggregate/queues endpoint;
+	// all other endpoints only operate on RedisConnOpt.
+	//
+	// This field is optional.
+	AggregateClusters map[string]asynq.RedisConnOpt
+
+	// TracerProvider is used to create OpenTelemetry spans for API requests, and the inspector operations
+	// and redis commands run by them.
+	// Trace context propagated in the W3C Trace Context headers of requests is continued.
+	//
+	// This field is optional. If this field is not set, requests are not traced.
+	TracerProvider trace.TracerProvider
+
 	// Set ReadOnly to true to restrict user to view-only mode.
 	ReadOnly bool
+
+	// UIAssetsDir specifies the directory to serve the web UI assets from.
+	//
+	// This field is optional. If this field is not set, the assets embedded in the binary are served.
+	UIAssetsDir string
 }
 
 // HTTPHandler is a http.Handler for asynqmon application.
@@ -65,7 +157,11 @@ func New(opts Options) *HTTPHandler {
 	if !ok {
 		panic(fmt.Sprintf("asnyqmon.New: unsupported RedisConnOpt type %T", opts.RedisConnOpt))
 	}
+	if opts.TracerProvider != nil {
+		rc.AddHook(newRedisTracingHook(opts.TracerProvider))
+	}
 	i := asynq.NewInspector(opts.RedisConnOpt)
+	c := asynq.NewClient(opts.RedisConnOpt)
 
 	// Make sure that RootPath starts with a slash if provided.
 	if opts.RootPath != "" && !strings.HasPrefix(opts.RootPath, "/") {
@@ -74,9 +170,32 @@ func New(opts Options) *HTTPHandler {
 	// Remove tailing slash from RootPath.
 	opts.RootPath = strings.TrimSuffix(opts.RootPath, "/")
 
+	var cache *queueStatsCache
+	jobs := newJobRegistry()
+	// Cancel running jobs before closing the inspector.
+	closers := []func() error{jobs.Close, rc.Close, i.Close, c.Close}
+	if opts.StatsCacheInterval > 0 {
+		cache = newQueueStatsCache(i, opts.StatsCacheInterval)
+		// Stop refreshing the cache before closing the inspector.
+		closers = append([]func() error{cache.Close}, closers...)
+	}
+
+	if opts.ClusterName == "" {
+		opts.ClusterName = defaultClusterName
+	}
+	clusters := map[string]*asynq.Inspector{opts.ClusterName: i}
+	for name, connOpt := range opts.AggregateClusters {
+		if _, ok := clusters[name]; ok {
+			panic(fmt.Sprintf("asynqmon.New: duplicate cluster name %q", name))
+		}
+		ci := asynq.NewInspector(connOpt)
+		clusters[name] = ci
+		closers = append(closers, ci.Close)
+	}
+
 	return &HTTPHandler{
-		router:   muxRouter(opts, rc, i),
-		closers:  []func() error{rc.Close, i.Close},
+		router:   muxRouter(opts, rc, i, c, cache, jobs, clusters),
+		closers:  closers,
 		rootPath: opts.RootPath,
 	}
 }
@@ -100,25 +219,65 @@ func (h *HTTPHandler) RootPath() string {
 //go:embed ui/build/*
 var staticContents embed.FS
 
-func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspector) *mux.Router {
+func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspector, client *asynq.Client, cache *queueStatsCache, jobs *jobRegistry, clusters map[string]*asynq.Inspector) *mux.Router {
 	router := mux.NewRouter().PathPrefix(opts.RootPath).Subrouter()
+	router.Use(RequestIDMiddleware)
 
 	var payloadFmt PayloadFormatter = DefaultPayloadFormatter
 	if opts.PayloadFormatter != nil {
 		payloadFmt = opts.PayloadFormatter
 	}
+	var redactor *redactingPayloadFormatter
+	if len(opts.PayloadRedactions) > 0 {
+		redactor = newRedactingPayloadFormatter(payloadFmt, opts.PayloadRedactions)
+		payloadFmt = redactor
+	}
+	// Truncate after redacting, since redaction requires the whole payload.
+	if opts.MaxPayloadDisplayBytes > 0 {
+		payloadFmt = &truncatingPayloadFormatter{pf: payloadFmt, max: opts.MaxPayloadDisplayBytes}
+	}
 
 	var resultFmt ResultFormatter = DefaultResultFormatter
 	if opts.ResultFormatter != nil {
 		resultFmt = opts.ResultFormatter
 	}
 
+	annotationTTL := DefaultAnnotationTTL
+	if opts.AnnotationTTL > 0 {
+		annotationTTL = opts.AnnotationTTL
+	}
+	annotations := &annotationStore{rc: rc, ttl: annotationTTL}
+
 	api := router.PathPrefix("/api").Subrouter()
+	// Respond with JSON errors for unmatched API paths instead of falling back to the UI.
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("API endpoint %q not found", r.URL.Path))
+	})
+	api.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, fmt.Sprintf("method %s is not allowed for %q", r.Method, r.URL.Path))
+	})
+	if opts.TracerProvider != nil {
+		api.Use(newTracingMiddleware(opts.TracerProvider))
+	}
+	if opts.MetricsRegisterer != nil {
+		api.Use(newHTTPMetrics(opts.MetricsRegisterer).middleware)
+	}
 
 	// Queue endpoints.
-	api.HandleFunc("/queues", newListQueuesHandlerFunc(inspector)).Methods("GET")
+	api.HandleFunc("/queues", newListQueuesHandlerFunc(inspector, cache)).Methods("GET")
+	api.HandleFunc("/queues:compare", newCompareQueuesHandlerFunc(inspector)).Methods("GET")
+	// Note: Registered before "/queues/{qname}" which would match "<qname>:export" otherwise.
+	api.HandleFunc("/queues/{qname}:export", newExportQueueHandlerFunc(inspector, redactor)).Methods("GET")
+	api.HandleFunc("/queues/{qname}:delete_preview", newDeleteQueuePreviewHandlerFunc(inspector)).Methods("GET")
 	api.HandleFunc("/queues/{qname}", newGetQueueHandlerFunc(inspector)).Methods("GET")
+	api.HandleFunc("/queues/{qname}:import", newImportQueueHandlerFunc(inspector, client, opts.PayloadValidator)).Methods("POST")
 	api.HandleFunc("/queues/{qname}", newDeleteQueueHandlerFunc(inspector)).Methods("DELETE")
+	api.HandleFunc("/queues/{qname}", newUpdateQueueHandlerFunc(inspector)).Methods("PUT")
+	api.HandleFunc("/queues/{qname}/size", newGetQueueSizeHandlerFunc(rc)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/oldest", newGetOldestTasksHandlerFunc(rc)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/task_types", newListTaskTypesHandlerFunc(inspector)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/stats:snapshot", newRecordStatsSnapshotHandlerFunc(rc)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/health", newGetQueueHealthHandlerFunc(inspector)).Methods("GET")
 	api.HandleFunc("/queues/{qname}:pause", newPauseQueueHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}:resume", newResumeQueueHandlerFunc(inspector)).Methods("POST")
 
@@ -126,12 +285,12 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 	api.HandleFunc("/queue_stats", newListQueueStatsHandlerFunc(inspector)).Methods("GET")
 
 	// Task endpoints.
-	api.HandleFunc("/queues/{qname}/active_tasks", newListActiveTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/active_tasks", newListActiveTasksHandlerFunc(inspector, rc, payloadFmt, opts.QueuePageSizes, annotations)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/active_tasks/{task_id}:cancel", newCancelActiveTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/active_tasks:cancel_all", newCancelAllActiveTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/active_tasks:batch_cancel", newBatchCancelActiveTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/pending_tasks", newListPendingTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/pending_tasks", newListPendingTasksHandlerFunc(inspector, payloadFmt, opts.QueuePageSizes, annotations)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/pending_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/pending_tasks:delete_all", newDeleteAllPendingTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/pending_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
@@ -139,42 +298,53 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 	api.HandleFunc("/queues/{qname}/pending_tasks:archive_all", newArchiveAllPendingTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/pending_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/scheduled_tasks", newListScheduledTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks", newListScheduledTasksHandlerFunc(inspector, payloadFmt, opts.QueuePageSizes, annotations)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:delete_all", newDeleteAllScheduledTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}:run", newRunTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:run_all", newRunAllScheduledTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:batch_run", newBatchRunTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks:run_by_type", newRunTasksByTypeHandlerFunc(inspector, "scheduled")).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}:archive", newArchiveTaskHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/scheduled_tasks/{task_id}:reschedule", newRescheduleTaskHandlerFunc(inspector, client)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/{state}_tasks:search", newSearchTasksHandlerFunc(inspector, payloadFmt, resultFmt, redactor)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/{state}_tasks/{task_id}:move", newMoveTaskHandlerFunc(inspector, client)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:archive_all", newArchiveAllScheduledTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/scheduled_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/retry_tasks", newListRetryTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/retry_tasks", newListRetryTasksHandlerFunc(inspector, payloadFmt, opts.QueuePageSizes, annotations)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/retry_tasks:error_summary", newErrorSummaryHandlerFunc(inspector, "retry")).Methods("GET")
 	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/retry_tasks:delete_all", newDeleteAllRetryTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/retry_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}:run", newRunTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:run_all", newRunAllRetryTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:batch_run", newBatchRunTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/retry_tasks:run_by_type", newRunTasksByTypeHandlerFunc(inspector, "retry")).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks/{task_id}:archive", newArchiveTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:archive_all", newArchiveAllRetryTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/retry_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/archived_tasks", newListArchivedTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/archived_tasks", newListArchivedTasksHandlerFunc(inspector, payloadFmt, opts.QueuePageSizes, annotations)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/archived_tasks:error_summary", newErrorSummaryHandlerFunc(inspector, "archived")).Methods("GET")
 	api.HandleFunc("/queues/{qname}/archived_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/archived_tasks:delete_all", newDeleteAllArchivedTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/archived_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/archived_tasks/{task_id}:run", newRunTaskHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/archived_tasks:run_all", newRunAllArchivedTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/archived_tasks/{task_id}:run_with_retries", newRunArchivedTaskWithRetriesHandlerFunc(inspector, client)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/archived_tasks:run_all_throttled", newRunAllArchivedTasksThrottledHandlerFunc(inspector, jobs)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/archived_tasks:batch_run", newBatchRunTasksHandlerFunc(inspector)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/archived_tasks:run_by_type", newRunTasksByTypeHandlerFunc(inspector, "archived")).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/completed_tasks", newListCompletedTasksHandlerFunc(inspector, payloadFmt, resultFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/completed_tasks", newListCompletedTasksHandlerFunc(inspector, payloadFmt, resultFmt, opts.QueuePageSizes, annotations)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/completed_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
+	api.HandleFunc("/queues/{qname}/completed_tasks/{task_id}/result", newGetTaskResultHandlerFunc(inspector)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/completed_tasks:delete_all", newDeleteAllCompletedTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/completed_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
 
-	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks", newListAggregatingTasksHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks", newListAggregatingTasksHandlerFunc(inspector, payloadFmt, opts.QueuePageSizes, annotations)).Methods("GET")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks/{task_id}", newDeleteTaskHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:delete_all", newDeleteAllAggregatingTasksHandlerFunc(inspector)).Methods("DELETE")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:batch_delete", newBatchDeleteTasksHandlerFunc(inspector)).Methods("POST")
@@ -185,17 +355,36 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:archive_all", newArchiveAllAggregatingTasksHandlerFunc(inspector)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/groups/{gname}/aggregating_tasks:batch_archive", newBatchArchiveTasksHandlerFunc(inspector)).Methods("POST")
 
+	api.HandleFunc("/queues/{qname}/tasks", newEnqueueTaskHandlerFunc(client, opts.PayloadValidator, payloadFmt, resultFmt)).Methods("POST")
 	api.HandleFunc("/queues/{qname}/tasks/{task_id}", newGetTaskHandlerFunc(inspector, payloadFmt, resultFmt)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/tasks/{task_id}/payload", newDownloadTaskPayloadHandlerFunc(inspector, redactor)).Methods("GET")
 
 	// Groups endponts
 	api.HandleFunc("/queues/{qname}/groups", newListGroupsHandlerFunc(inspector)).Methods("GET")
 
+	// Batch endpoint.
+	api.HandleFunc("/batch", newBatchHandlerFunc(inspector)).Methods("POST")
+
+	// Task events endpoint.
+	api.HandleFunc("/events", newListEventsHandlerFunc(inspector)).Methods("GET")
+
+	// Job endpoints.
+	api.HandleFunc("/jobs/{job_id}", newGetJobHandlerFunc(jobs)).Methods("GET")
+	api.HandleFunc("/jobs/{job_id}:cancel", newCancelJobHandlerFunc(jobs)).Methods("POST")
+
 	// Servers endpoints.
-	api.HandleFunc("/servers", newListServersHandlerFunc(inspector, payloadFmt)).Methods("GET")
+	if !opts.DisableServersAPI {
+		api.HandleFunc("/servers", newListServersHandlerFunc(inspector, payloadFmt)).Methods("GET")
+		api.HandleFunc("/servers:prune", newPruneServersHandlerFunc(rc)).Methods("POST")
+		api.HandleFunc("/queues:priorities", newListQueuePrioritiesHandlerFunc(inspector)).Methods("GET")
+	}
 
 	// Scheduler Entry endpoints.
-	api.HandleFunc("/scheduler_entries", newListSchedulerEntriesHandlerFunc(inspector, payloadFmt)).Methods("GET")
-	api.HandleFunc("/scheduler_entries/{entry_id}/enqueue_events", newListSchedulerEnqueueEventsHandlerFunc(inspector)).Methods("GET")
+	if !opts.DisableSchedulerAPI {
+		api.HandleFunc("/scheduler_entries", newListSchedulerEntriesHandlerFunc(inspector, payloadFmt)).Methods("GET")
+		api.HandleFunc("/scheduler_entries/{entry_id}", newGetSchedulerEntryHandlerFunc(inspector, payloadFmt)).Methods("GET")
+		api.HandleFunc("/scheduler_entries/{entry_id}/enqueue_events", newListSchedulerEnqueueEventsHandlerFunc(inspector)).Methods("GET")
+	}
 
 	// Redis info endpoint.
 	switch c := rc.(type) {
@@ -204,6 +393,21 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 	case *redis.Client:
 		api.HandleFunc("/redis_info", newRedisInfoHandlerFunc(c)).Methods("GET")
 	}
+	api.HandleFunc("/redis/queue_usage", newQueueUsageHandlerFunc(rc)).Methods("GET")
+
+	// Aggregate endpoints.
+	api.HandleFunc("/aggregate/queues", newAggregateQueuesHandlerFunc(clusters)).Methods("GET")
+
+	// Redis diagnostics endpoints.
+	if opts.EnableRedisDiagnosticsAPI {
+		api.HandleFunc("/redis/slowlog", newSlowlogHandlerFunc(rc)).Methods("GET")
+		api.HandleFunc("/redis/latency", newLatencyHandlerFunc(rc)).Methods("GET")
+	}
+
+	// Task annotation endpoints.
+	api.HandleFunc("/queues/{qname}/tasks/{task_id}/annotations", newGetTaskAnnotationsHandlerFunc(annotations)).Methods("GET")
+	api.HandleFunc("/queues/{qname}/tasks/{task_id}/annotations", newAnnotateTaskHandlerFunc(inspector, annotations)).Methods("POST")
+	api.HandleFunc("/queues/{qname}/annotations:batch", newBatchAnnotateTasksHandlerFunc(annotations)).Methods("POST")
 
 	// Time series metrics endpoints.
 	api.HandleFunc("/metrics", newGetMetricsHandlerFunc(http.DefaultClient, opts.PrometheusAddress)).Methods("GET")
@@ -213,11 +417,25 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 		api.Use(restrictToReadOnly)
 	}
 
+	// Registered after restrictToReadOnly so that requests rejected in read-only mode are not recorded.
+	idempotencyKeyTTL := DefaultIdempotencyKeyTTL
+	if opts.IdempotencyKeyTTL > 0 {
+		idempotencyKeyTTL = opts.IdempotencyKeyTTL
+	}
+	api.Use(newIdempotencyMiddleware(&redisIdempotencyStore{rc: rc}, idempotencyKeyTTL))
+
+	var uiAssets fs.FS
+	if opts.UIAssetsDir != "" {
+		uiAssets = os.DirFS(opts.UIAssetsDir)
+	} else {
+		// Sub never fails for a valid directory name.
+		uiAssets, _ = fs.Sub(staticContents, "ui/build")
+	}
+
 	// Everything else, route to uiAssetsHandler.
 	router.NotFoundHandler = &uiAssetsHandler{
 		rootPath:       opts.RootPath,
-		contents:       staticContents,
-		staticDirPath:  "ui/build",
+		contents:       uiAssets,
 		indexFileName:  "index.html",
 		prometheusAddr: opts.PrometheusAddress,
 		readOnly:       opts.ReadOnly,
@@ -230,7 +448,7 @@ func muxRouter(opts Options, rc redis.UniversalClient, inspector *asynq.Inspecto
 func restrictToReadOnly(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" && r.Method != "" {
-			http.Error(w, fmt.Sprintf("API Server is running in read-only mode: %s request is not allowed", r.Method), http.StatusMethodNotAllowed)
+			respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, fmt.Sprintf("API Server is running in read-only mode: %s request is not allowed", r.Method))
 			return
 		}
 		h.ServeHTTP(w, r)
@@ -0,0 +1,41 @@
+package asynqmon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestUnmatchedPaths(t *testing.T) {
+	// Redis is not needed since none of the requests reach a handler which uses it.
+	h := New(Options{RedisConnOpt: asynq.RedisClientOpt{Addr: "localhost:6379"}})
+	defer h.Close()
+
+	tests := []struct {
+		method          string
+		path            string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"GET", "/api/queuez", http.StatusNotFound, "application/json"},
+		{"GET", "/api/queues/default/unknown_tasks/x/y", http.StatusNotFound, "application/json"},
+		{"PATCH", "/api/queues", http.StatusMethodNotAllowed, "application/json"},
+		// Deep links into the UI are served the index page.
+		{"GET", "/queues/default", http.StatusOK, "text/html"},
+		{"GET", "/", http.StatusOK, "text/html"},
+	}
+
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, w.Code, tc.wantStatus)
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.wantContentType) {
+			t.Errorf("%s %s: got Content-Type %q, want %q", tc.method, tc.path, got, tc.wantContentType)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package asynqmon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMetricsMiddleware(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m := newHTTPMetrics(reg)
+
+	router := mux.NewRouter()
+	router.Use(m.middleware)
+	router.HandleFunc("/api/queues/{qname}/tasks/{task_id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "task not found", http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/api/queues/default/tasks/123", "/api/queues/critical/tasks/456"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	want := `
+# HELP asynqmon_http_requests_total Number of API requests processed, by route, method and status code.
+# TYPE asynqmon_http_requests_total counter
+asynqmon_http_requests_total{code="404",method="GET",route="/api/queues/{qname}/tasks/{task_id}"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "asynqmon_http_requests_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(m.duration); n != 1 {
+		t.Errorf("got %d duration series, want 1", n)
+	}
+}
@@ -0,0 +1,247 @@
+package asynqmon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - middleware to deduplicate mutating requests using the Idempotency-Key header
+// ****************************************************************************
+
+// IdempotencyKeyHeader is the header used to specify the idempotency key of a mutating request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader is set in the response replayed for a duplicate request.
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyKeyTTL is the default duration to remember the result of a request with an idempotency key.
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// Maximum size of a response body stored for an idempotency key.
+// Requests with larger responses are not deduplicated.
+const maxIdempotentResponseSize = 1 << 20
+
+// Maximum size of a request body hashed to detect reuse of an idempotency key with a different request.
+// Requests with larger bodies (e.g. queue imports) are not deduplicated.
+const maxIdempotentRequestSize = maxRequestBodySize
+
+// idempotencyLockTTL is how long a request in progress holds its idempotency key.
+// It is short so that a key is released soon if the process dies while handling the request;
+// the TTL is extended to the configured TTL once the response is recorded.
+const idempotencyLockTTL = time.Minute
+
+// idempotencyKeyPrefix is the prefix of redis keys used to store responses.
+const idempotencyKeyPrefix = "asynqmon:idempotency:"
+
+// idempotentResponse is the response stored for an idempotency key.
+// A response with zero status marks a request in progress.
+type idempotentResponse struct {
+	// SHA-256 hash of the request body, in hex.
+	BodyHash    string `json:"body_hash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// idempotencyStore stores the responses recorded for idempotency keys.
+type idempotencyStore interface {
+	// setNX stores value for key if key does not exist, and reports whether it was stored.
+	setNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// get returns the value stored for key, or nil if key does not exist.
+	get(ctx context.Context, key string) ([]byte, error)
+	set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	del(ctx context.Context, key string) error
+}
+
+// redisIdempotencyStore is an idempotencyStore backed by redis.
+type redisIdempotencyStore struct {
+	rc redis.UniversalClient
+}
+
+func (s *redisIdempotencyStore) setNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.rc.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (s *redisIdempotencyStore) get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.rc.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *redisIdempotencyStore) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.rc.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) del(ctx context.Context, key string) error {
+	return s.rc.Del(ctx, key).Err()
+}
+
+// newIdempotencyMiddleware returns a middleware which records the response of mutating requests
+// with an Idempotency-Key header in store for ttl, and replays the recorded response for
+// subsequent requests with the same key, method and path instead of handling them again.
+// Reusing a key with a different request body is rejected with 422 Unprocessable Entity.
+// Responses with a 5xx status are not recorded so that the request can be retried.
+func newIdempotencyMiddleware(store idempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !isValidRequestID(key) {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for %s header", IdempotencyKeyHeader))
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestSize+1))
+			if err != nil {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("could not read request body: %v", err))
+				return
+			}
+			if len(body) > maxIdempotentRequestSize {
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			bodyHash := hex.EncodeToString(sum[:])
+
+			ctx := r.Context()
+			rkey := idempotencyKeyPrefix + r.Method + ":" + r.URL.Path + ":" + key
+
+			pending, _ := json.Marshal(idempotentResponse{BodyHash: bodyHash})
+			ok, err := store.setNX(ctx, rkey, pending, idempotencyLockTTL)
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			if !ok {
+				replayIdempotentResponse(w, r, store, rkey, bodyHash)
+				return
+			}
+
+			// Release the key if the handler panics, so that the request can be retried.
+			// Record the response even if the client has gone away in the meantime.
+			recorded := false
+			defer func() {
+				if !recorded {
+					if err := store.del(context.Background(), rkey); err != nil {
+						logRequestf(r, "error: could not delete idempotency key %q: %v", key, err)
+					}
+				}
+			}()
+
+			rec := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= 500 || rec.overflow {
+				return
+			}
+			data, err := json.Marshal(idempotentResponse{
+				BodyHash:    bodyHash,
+				Status:      rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			})
+			if err == nil {
+				err = store.set(context.Background(), rkey, data, ttl)
+			}
+			if err != nil {
+				logRequestf(r, "error: could not store response for idempotency key %q: %v", key, err)
+				return
+			}
+			recorded = true
+		})
+	}
+}
+
+// replayIdempotentResponse writes the response recorded for the key rkey,
+// if it was recorded for a request with the same body.
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, store idempotencyStore, rkey, bodyHash string) {
+	data, err := store.get(r.Context(), rkey)
+	if err != nil {
+		writeInternalServerError(w, r, err)
+		return
+	}
+	if data == nil {
+		// The key expired, or the original request failed, in the meantime.
+		respondError(w, http.StatusConflict, errCodeConflict, "request with the same idempotency key failed, retry the request")
+		return
+	}
+	var resp idempotentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		writeInternalServerError(w, r, err)
+		return
+	}
+	if resp.BodyHash != bodyHash {
+		respondError(w, http.StatusUnprocessableEntity, errCodeUnprocessable, "idempotency key was used for a request with a different body")
+		return
+	}
+	if resp.Status == 0 {
+		respondError(w, http.StatusConflict, errCodeConflict, "request with the same idempotency key is in progress")
+		return
+	}
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// responseCapture is a http.ResponseWriter which records the status and body of the response
+// while writing it to the underlying ResponseWriter.
+type responseCapture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool // true if the body exceeded maxIdempotentResponseSize
+}
+
+func (c *responseCapture) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.status = code
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	c.wroteHeader = true
+	if !c.overflow {
+		if c.body.Len()+len(b) > maxIdempotentResponseSize {
+			c.overflow = true
+			c.body.Reset()
+		} else {
+			c.body.Write(b)
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the underlying ResponseWriter so that streaming responses are not held back
+// while they are captured.
+func (c *responseCapture) Flush() {
+	c.wroteHeader = true
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
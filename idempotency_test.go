@@ -0,0 +1,109 @@
+package asynqmon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeIdempotencyStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	ttls map[string]time.Duration
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{data: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (s *fakeIdempotencyStore) setNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		return false, nil
+	}
+	s.data[key], s.ttls[key] = value, ttl
+	return true, nil
+}
+
+func (s *fakeIdempotencyStore) get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *fakeIdempotencyStore) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key], s.ttls[key] = value, ttl
+	return nil
+}
+
+func (s *fakeIdempotencyStore) del(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	delete(s.ttls, key)
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	calls := 0
+	h := newIdempotencyMiddleware(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc"}`))
+	}))
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/queues/default/tasks", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(`{"type":"a"}`); w.Code != http.StatusCreated {
+		t.Fatalf("first request status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	for key, ttl := range store.ttls {
+		if ttl != time.Hour {
+			t.Errorf("TTL of %q = %v after the response is recorded, want %v", key, ttl, time.Hour)
+		}
+	}
+
+	w := do(`{"type":"a"}`)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"id":"abc"}` || w.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("duplicate request got %d %q, want the replayed response", w.Code, w.Body.String())
+	}
+	if w := do(`{"type":"b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("request with a different body status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyMiddlewarePanic(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	h := newIdempotencyMiddleware(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ttl := store.ttls[idempotencyKeyPrefix+"POST:/api/x:key-1"]; ttl != idempotencyLockTTL {
+			t.Errorf("TTL of the key in progress = %v, want %v", ttl, idempotencyLockTTL)
+		}
+		panic("boom")
+	}))
+	req := httptest.NewRequest("POST", "/api/x", nil)
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	if len(store.data) != 0 {
+		t.Errorf("idempotency key was not released after a panic: %v", store.data)
+	}
+}
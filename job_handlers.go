@@ -0,0 +1,40 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for job related endpoints
+// ****************************************************************************
+
+func newGetJobHandlerFunc(jobs *jobRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["job_id"]
+		j := jobs.get(id)
+		if j == nil {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("job %q not found", id))
+			return
+		}
+		writeResponseJSON(w, j.info())
+	}
+}
+
+// newCancelJobHandlerFunc returns a handler which cancels a running job.
+// Canceling a job which has already finished has no effect.
+func newCancelJobHandlerFunc(jobs *jobRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["job_id"]
+		j := jobs.get(id)
+		if j == nil {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("job %q not found", id))
+			return
+		}
+		j.stop()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
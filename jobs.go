@@ -0,0 +1,193 @@
+package asynqmon
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - in-memory registry of long running jobs started via the API
+// ****************************************************************************
+
+// State of a job.
+const (
+	jobStateRunning   = "running"
+	jobStateCompleted = "completed"
+	jobStateCanceled  = "canceled"
+	jobStateFailed    = "failed"
+)
+
+// finishedJobRetention is the duration to keep a finished job so that clients can see its result.
+const finishedJobRetention = time.Hour
+
+// job is a long running operation, which can be polled for progress and canceled.
+type job struct {
+	id    string
+	queue string
+
+	mu         sync.Mutex
+	state      string
+	total      int
+	truncated  bool
+	processed  int
+	failed     int
+	err        string
+	startedAt  time.Time
+	finishedAt time.Time
+
+	cancel chan struct{}
+	once   sync.Once
+}
+
+// jobInfo is the JSON representation of a job.
+type jobInfo struct {
+	ID    string `json:"id"`
+	Queue string `json:"queue"`
+	State string `json:"state"`
+	Total int    `json:"total"`
+	// Truncated indicates that the job only processes the first Total items
+	// because of a limit, so it must be run again to process the rest.
+	Truncated bool `json:"truncated"`
+	Processed int  `json:"processed"`
+	Failed    int  `json:"failed"`
+	// Error message if the job failed.
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (j *job) info() *jobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := &jobInfo{
+		ID:        j.id,
+		Queue:     j.queue,
+		State:     j.state,
+		Total:     j.total,
+		Truncated: j.truncated,
+		Processed: j.processed,
+		Failed:    j.failed,
+		Error:     j.err,
+		StartedAt: j.startedAt,
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		info.FinishedAt = &t
+	}
+	return info
+}
+
+// setTotal sets the total number of items the job processes,
+// and whether it is truncated from a larger number of items.
+func (j *job) setTotal(n int, truncated bool) {
+	j.mu.Lock()
+	j.total = n
+	j.truncated = truncated
+	j.mu.Unlock()
+}
+
+// record records the result of processing an item.
+func (j *job) record(err error) {
+	j.mu.Lock()
+	if err != nil {
+		j.failed++
+	} else {
+		j.processed++
+	}
+	j.mu.Unlock()
+}
+
+// finish marks the job as done with the given state.
+func (j *job) finish(state string, err error) {
+	j.mu.Lock()
+	j.state = state
+	if err != nil {
+		j.err = err.Error()
+	}
+	j.finishedAt = time.Now()
+	j.mu.Unlock()
+}
+
+// done returns a channel which is closed when the job is canceled.
+func (j *job) done() <-chan struct{} {
+	return j.cancel
+}
+
+func (j *job) stop() {
+	j.once.Do(func() { close(j.cancel) })
+}
+
+// jobRegistry keeps track of jobs started by this process.
+// Jobs are not persisted, so they are lost when the process exits.
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*job)}
+}
+
+// start registers a new job for the queue and runs fn in a new goroutine.
+// fn should return when the job's done channel is closed.
+func (reg *jobRegistry) start(qname string, fn func(j *job) error) *job {
+	j := &job{
+		id:        uuid.NewString(),
+		queue:     qname,
+		state:     jobStateRunning,
+		startedAt: time.Now(),
+		cancel:    make(chan struct{}),
+	}
+	reg.mu.Lock()
+	reg.pruneLocked()
+	reg.jobs[j.id] = j
+	reg.mu.Unlock()
+
+	go func() {
+		err := fn(j)
+		select {
+		case <-j.done():
+			j.finish(jobStateCanceled, err)
+		default:
+			if err != nil {
+				j.finish(jobStateFailed, err)
+			} else {
+				j.finish(jobStateCompleted, nil)
+			}
+		}
+	}()
+	return j
+}
+
+// get returns the job with the given id, or nil if not found.
+func (reg *jobRegistry) get(id string) *job {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.jobs[id]
+}
+
+// pruneLocked removes jobs which finished more than finishedJobRetention ago.
+// reg.mu must be held.
+func (reg *jobRegistry) pruneLocked() {
+	for id, j := range reg.jobs {
+		j.mu.Lock()
+		expired := !j.finishedAt.IsZero() && time.Since(j.finishedAt) > finishedJobRetention
+		j.mu.Unlock()
+		if expired {
+			delete(reg.jobs, id)
+		}
+	}
+}
+
+// Close cancels all running jobs.
+func (reg *jobRegistry) Close() error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, j := range reg.jobs {
+		j.stop()
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package asynqmon
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, j *job) *jobInfo {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if info := j.info(); info.State != jobStateRunning {
+			return info
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", j.id)
+	return nil
+}
+
+func TestJobRegistry(t *testing.T) {
+	reg := newJobRegistry()
+
+	completed := reg.start("default", func(j *job) error {
+		j.setTotal(2, true)
+		j.record(nil)
+		j.record(errors.New("task not found"))
+		return nil
+	})
+	if got := reg.get(completed.id); got != completed {
+		t.Fatalf("get(%q) = %v, want %v", completed.id, got, completed)
+	}
+	info := waitForJob(t, completed)
+	if info.State != jobStateCompleted || info.Total != 2 || !info.Truncated || info.Processed != 1 || info.Failed != 1 || info.FinishedAt == nil {
+		t.Errorf("completed job info = %+v", info)
+	}
+
+	canceled := reg.start("default", func(j *job) error {
+		<-j.done()
+		return nil
+	})
+	canceled.stop()
+	if info := waitForJob(t, canceled); info.State != jobStateCanceled {
+		t.Errorf("canceled job state = %q, want %q", info.State, jobStateCanceled)
+	}
+
+	failed := reg.start("default", func(j *job) error {
+		return errors.New("redis is down")
+	})
+	if info := waitForJob(t, failed); info.State != jobStateFailed || info.Error != "redis is down" {
+		t.Errorf("failed job info = %+v", info)
+	}
+
+	if got := reg.get("nonexistent"); got != nil {
+		t.Errorf("get(%q) = %v, want nil", "nonexistent", got)
+	}
+}
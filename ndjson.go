@@ -0,0 +1,113 @@
+package asynqmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - helpers to stream task lists as newline delimited JSON
+// ****************************************************************************
+
+// ndjsonContentType is the media type of newline delimited JSON.
+const ndjsonContentType = "application/x-ndjson"
+
+// Default and maximum number of tasks streamed by a single NDJSON request.
+const (
+	defaultNDJSONMaxTotal = 10000
+	maxNDJSONMaxTotal     = 100000
+)
+
+// acceptsNDJSON reports whether the request asks for an NDJSON response via the Accept header.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(v)); err == nil && mt == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// streamTasksNDJSON pages through the tasks returned by list and writes them one per line,
+// flushing after each page so that the whole list is never held in memory.
+// Tasks are streamed in their natural order; the page and sort params are ignored.
+//
+// Optional query params:
+// `max_total`: maximum number of tasks to stream (default 10000, max 100000)
+// `fields`: fields to project each task to, as in list responses (see parseFieldProjection);
+// since there is no response object to note them in, unknown field names are ignored silently
+//
+// Errors after the first task has been written cannot be reported with a status code,
+// so they are logged and the stream ends early. Like queue exports, the stream is cut off
+// if it does not finish within the write timeout of the server.
+func streamTasksNDJSON(w http.ResponseWriter, r *http.Request, list listTasksFunc, state string, convert func(*asynq.TaskInfo) interface{}) {
+	maxTotal := defaultNDJSONMaxTotal
+	if s := r.URL.Query().Get("max_total"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for max_total: %q", s))
+			return
+		}
+		maxTotal = n
+	}
+	if maxTotal > maxNDJSONMaxTotal {
+		maxTotal = maxNDJSONMaxTotal
+	}
+
+	const batchSize = 100
+	qname := mux.Vars(r)["qname"]
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	var projection *fieldProjection
+	written := 0
+	for page := 1; written < maxTotal; page++ {
+		tasks, err := list(qname, asynq.Page(page), asynq.PageSize(batchSize))
+		if err != nil {
+			if written == 0 {
+				writeInternalServerError(w, r, err)
+			} else {
+				logRequestf(r, "error: could not list tasks of queue %q: %v", qname, err)
+			}
+			return
+		}
+		if written == 0 {
+			w.Header().Set("Content-Type", ndjsonContentType)
+		}
+		for _, t := range tasks {
+			if written == maxTotal {
+				break
+			}
+			var v interface{} = convert(t)
+			if written == 0 {
+				projection, _ = parseFieldProjection(r, reflect.TypeOf(v), state)
+			}
+			if projection != nil {
+				if v, err = projection.project(v); err != nil {
+					logRequestf(r, "error: could not project task %q: %v", t.ID, err)
+					return
+				}
+			}
+			if err := enc.Encode(v); err != nil {
+				logRequestf(r, "error: could not write task %q: %v", t.ID, err)
+				return
+			}
+			written++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(tasks) < batchSize {
+			return
+		}
+	}
+}
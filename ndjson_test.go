@@ -0,0 +1,81 @@
+package asynqmon
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestAcceptsNDJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/x-ndjson", true},
+		{"application/json;q=0.9, application/x-ndjson", true},
+	}
+
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/api/queues/default/pending_tasks", nil)
+		r.Header.Set("Accept", tc.accept)
+		if got := acceptsNDJSON(r); got != tc.want {
+			t.Errorf("acceptsNDJSON with Accept %q = %t, want %t", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestStreamTasksNDJSON(t *testing.T) {
+	// list returns 250 tasks in pages of 100 tasks.
+	list := func(qname string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+		const size = 100
+		page := 0
+		for _, opt := range opts {
+			// asynq does not expose the list options, so compare them with known values.
+			for p := 1; p <= 10; p++ {
+				if opt == asynq.Page(p) {
+					page = p
+				}
+			}
+		}
+		var tasks []*asynq.TaskInfo
+		for i := (page - 1) * size; i < page*size && i < 250; i++ {
+			tasks = append(tasks, &asynq.TaskInfo{ID: fmt.Sprintf("task%d", i), Queue: qname})
+		}
+		return tasks, nil
+	}
+	convert := func(t *asynq.TaskInfo) interface{} { return toPendingTask(t, DefaultPayloadFormatter) }
+
+	tests := []struct {
+		query     string
+		wantLines int
+		wantFirst string
+	}{
+		{"", 250, `{"id":"task0","type":"","payload":"non-printable bytes","payload_truncated":false,"payload_size":0,"queue":"default","max_retry":0,"retried":0,"error_message":""}`},
+		{"?fields=id", 250, `{"id":"task0"}`},
+		{"?fields=id&max_total=120", 120, `{"id":"task0"}`},
+		{"?fields=id,state,bogus", 250, `{"id":"task0","state":"pending"}`},
+	}
+
+	for _, tc := range tests {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/api/queues/default/pending_tasks"+tc.query, nil), map[string]string{"qname": "default"})
+		w := httptest.NewRecorder()
+		streamTasksNDJSON(w, r, list, "pending", convert)
+		if got := w.Header().Get("Content-Type"); got != ndjsonContentType {
+			t.Errorf("streamTasksNDJSON%s wrote Content-Type %q, want %q", tc.query, got, ndjsonContentType)
+		}
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		if len(lines) != tc.wantLines {
+			t.Errorf("streamTasksNDJSON%s wrote %d lines, want %d", tc.query, len(lines), tc.wantLines)
+		}
+		if lines[0] != tc.wantFirst {
+			t.Errorf("streamTasksNDJSON%s wrote first line %q, want %q", tc.query, lines[0], tc.wantFirst)
+		}
+	}
+}
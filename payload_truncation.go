@@ -0,0 +1,42 @@
+package asynqmon
+
+import "unicode/utf8"
+
+// ****************************************************************************
+// This file defines:
+//   - PayloadFormatter which truncates large payloads
+// ****************************************************************************
+
+// truncatingPayloadFormatter is a PayloadFormatter which truncates the output of the
+// underlying formatter to at most max bytes, so that large payloads don't bloat API responses.
+type truncatingPayloadFormatter struct {
+	pf  PayloadFormatter
+	max int
+}
+
+func (f *truncatingPayloadFormatter) FormatPayload(taskType string, payload []byte) string {
+	s, _ := f.format(taskType, payload)
+	return s
+}
+
+// format returns the formatted payload and reports whether it was truncated.
+func (f *truncatingPayloadFormatter) format(taskType string, payload []byte) (string, bool) {
+	s := f.pf.FormatPayload(taskType, payload)
+	if len(s) <= f.max {
+		return s, false
+	}
+	n := f.max
+	// Avoid cutting a multi-byte character in half.
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
+
+// formatPayload formats the payload with pf and reports whether the formatted payload was truncated.
+func formatPayload(pf PayloadFormatter, taskType string, payload []byte) (s string, truncated bool) {
+	if f, ok := pf.(*truncatingPayloadFormatter); ok {
+		return f.format(taskType, payload)
+	}
+	return pf.FormatPayload(taskType, payload), false
+}
@@ -0,0 +1,24 @@
+package asynqmon
+
+import "testing"
+
+func TestTruncatingPayloadFormatter(t *testing.T) {
+	f := &truncatingPayloadFormatter{pf: DefaultPayloadFormatter, max: 8}
+	tests := []struct {
+		payload       string
+		want          string
+		wantTruncated bool
+	}{
+		{`{"a":1}`, `{"a":1}`, false},
+		{`{"a":"b"}x`, `{"a":"b"`, true},
+		// Does not cut a multi-byte character in half.
+		{`{"a":"日本"}`, `{"a":"`, true},
+	}
+
+	for _, tc := range tests {
+		got, truncated := formatPayload(f, "task", []byte(tc.payload))
+		if got != tc.want || truncated != tc.wantTruncated {
+			t.Errorf("formatPayload(%q) = (%q, %t), want (%q, %t)", tc.payload, got, truncated, tc.want, tc.wantTruncated)
+		}
+	}
+}
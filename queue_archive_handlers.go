@@ -0,0 +1,264 @@
+package asynqmon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for queue export and import endpoints
+// ****************************************************************************
+
+// exportedTask is a task in a queue export, written as a line of JSON.
+type exportedTask struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Payload []byte `json:"payload"` // base64 encoded in JSON
+	State   string `json:"state"`
+	Group   string `json:"group,omitempty"`
+
+	MaxRetry         int        `json:"max_retry"`
+	Retried          int        `json:"retried"`
+	LastErr          string     `json:"error_message,omitempty"`
+	LastFailedAt     *time.Time `json:"last_failed_at,omitempty"`
+	TimeoutSeconds   int64      `json:"timeout_seconds,omitempty"`
+	Deadline         *time.Time `json:"deadline,omitempty"`
+	NextProcessAt    *time.Time `json:"next_process_at,omitempty"`
+	RetentionSeconds int64      `json:"retention_seconds,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	Result           []byte     `json:"result,omitempty"` // base64 encoded in JSON
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func toExportedTask(info *asynq.TaskInfo) *exportedTask {
+	return &exportedTask{
+		ID:               info.ID,
+		Type:             info.Type,
+		Payload:          info.Payload,
+		State:            info.State.String(),
+		Group:            info.Group,
+		MaxRetry:         info.MaxRetry,
+		Retried:          info.Retried,
+		LastErr:          info.LastErr,
+		LastFailedAt:     timePtr(info.LastFailedAt),
+		TimeoutSeconds:   int64(info.Timeout.Seconds()),
+		Deadline:         timePtr(info.Deadline),
+		NextProcessAt:    timePtr(info.NextProcessAt),
+		RetentionSeconds: int64(info.Retention.Seconds()),
+		CompletedAt:      timePtr(info.CompletedAt),
+		Result:           info.Result,
+	}
+}
+
+// newExportQueueHandlerFunc returns a handler which streams all tasks in the queue as JSON Lines,
+// one task per line. Tasks are read a page at a time to bound memory usage.
+//
+// The export is not a consistent snapshot: tasks which change state while the export is running
+// may be missing or appear twice. The write timeout of the server bounds the duration of an export,
+// so exporting a large queue requires a server with a long or no write timeout
+// (see the --write-timeout flag of the asynqmon command).
+//
+// If redactor is non-nil, the configured fields are redacted from the exported payloads like
+// everywhere else in the UI, so an export of a queue with redacted task types is lossy:
+// importing it enqueues tasks with the redacted values.
+func newExportQueueHandlerFunc(inspector *asynq.Inspector, redactor *redactingPayloadFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		qname := mux.Vars(r)["qname"]
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !contains(qnames, qname) {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+		groups, err := inspector.Groups(qname)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		lists := []listTasksFunc{
+			inspector.ListPendingTasks,
+			inspector.ListActiveTasks,
+			inspector.ListScheduledTasks,
+			inspector.ListRetryTasks,
+			inspector.ListArchivedTasks,
+			inspector.ListCompletedTasks,
+		}
+		for _, g := range groups {
+			gname := g.Group
+			lists = append(lists, func(qname string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+				return inspector.ListAggregatingTasks(qname, gname, opts...)
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", qname+".jsonl"))
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		for _, list := range lists {
+			const batchSize = 100
+			for page := 1; ; page++ {
+				tasks, err := list(qname, asynq.Page(page), asynq.PageSize(batchSize))
+				if err != nil {
+					// The response has already started, so the error can only be logged.
+					logRequestf(r, "error: could not export queue %q: %v", qname, err)
+					return
+				}
+				for _, t := range tasks {
+					et := toExportedTask(t)
+					if redactor != nil {
+						et.Payload = redactor.redact(t.Type, t.Payload)
+					}
+					if err := enc.Encode(et); err != nil {
+						logRequestf(r, "error: could not export queue %q: %v", qname, err)
+						return
+					}
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+				if len(tasks) < batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Maximum request body size in bytes for import.
+// Allow up to 1GB in size; the body is decoded one task at a time.
+const maxImportBodySize = 1 << 30
+
+// Maximum number of errors included in the import response.
+const maxImportErrors = 20
+
+type importQueueResponse struct {
+	// Number of tasks imported.
+	Imported int `json:"imported"`
+	// Number of tasks skipped, because a task with the same id already exists in the queue
+	// or the task cannot be restored (completed tasks).
+	Skipped int `json:"skipped"`
+	// Number of tasks failed to be imported.
+	Failed int `json:"failed"`
+	// Errors of the first failed tasks.
+	Errors []string `json:"errors"`
+}
+
+// newImportQueueHandlerFunc returns a handler which enqueues tasks read as JSON Lines, as produced by
+// the export endpoint, into the queue. Tasks keep their id, so importing the same export twice is safe.
+// If pv is non-nil, payloads are validated and tasks with an invalid payload are reported as failed.
+//
+// Not every state can be restored faithfully through the asynq Client:
+//   - active tasks are restored as pending tasks, since they are not being processed by any worker.
+//   - archived tasks are restored as archived, but lose their error message and retry count.
+//   - completed tasks are skipped.
+func newImportQueueHandlerFunc(inspector *asynq.Inspector, client *asynq.Client, pv PayloadValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		qname := mux.Vars(r)["qname"]
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBodySize)
+		dec := json.NewDecoder(bufio.NewReader(r.Body))
+		dec.DisallowUnknownFields()
+
+		resp := importQueueResponse{Errors: make([]string, 0)}
+		fail := func(id string, err error) {
+			resp.Failed++
+			if len(resp.Errors) < maxImportErrors {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("task %q: %v", id, err))
+			}
+		}
+		for line := 1; ; line++ {
+			var t exportedTask
+			if err := dec.Decode(&t); err == io.EOF {
+				break
+			} else if err != nil {
+				// The decoder cannot recover from syntax errors, so stop reading.
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid task at line %d: %v", line, err))
+				return
+			}
+			if t.State == "completed" {
+				resp.Skipped++
+				continue
+			}
+			if pv != nil {
+				if err := pv.ValidatePayload(t.Type, t.Payload); err != nil {
+					fail(t.ID, err)
+					continue
+				}
+			}
+			_, err := client.Enqueue(asynq.NewTask(t.Type, t.Payload), importTaskOptions(qname, &t, time.Now())...)
+			switch {
+			case errors.Is(err, asynq.ErrTaskIDConflict):
+				resp.Skipped++
+				continue
+			case err != nil:
+				fail(t.ID, err)
+				continue
+			}
+			if t.State == "archived" {
+				if err := inspector.ArchiveTask(qname, t.ID); err != nil {
+					// Do not leave the task scheduled to run, since it was dead in the export.
+					if derr := inspector.DeleteTask(qname, t.ID); derr != nil {
+						logRequestf(r, "error: could not delete task %q after failing to archive it: %v", t.ID, derr)
+					}
+					fail(t.ID, err)
+					continue
+				}
+			}
+			resp.Imported++
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// Delay to schedule archived tasks with on import, so that they are not processed
+// before they are moved to the archived state.
+const importArchivedTaskDelay = 100 * 365 * 24 * time.Hour
+
+// importTaskOptions returns the options to enqueue the exported task into the queue at now.
+// Archived tasks are scheduled far in the future, to be archived right after they are enqueued.
+func importTaskOptions(qname string, t *exportedTask, now time.Time) []asynq.Option {
+	opts := []asynq.Option{
+		asynq.Queue(qname),
+		asynq.TaskID(t.ID),
+		asynq.MaxRetry(t.MaxRetry),
+	}
+	if t.TimeoutSeconds > 0 {
+		opts = append(opts, asynq.Timeout(time.Duration(t.TimeoutSeconds)*time.Second))
+	}
+	if t.Deadline != nil {
+		opts = append(opts, asynq.Deadline(*t.Deadline))
+	}
+	if t.RetentionSeconds > 0 {
+		opts = append(opts, asynq.Retention(time.Duration(t.RetentionSeconds)*time.Second))
+	}
+	if t.Group != "" {
+		opts = append(opts, asynq.Group(t.Group))
+	}
+	switch {
+	case (t.State == "scheduled" || t.State == "retry") && t.NextProcessAt != nil:
+		opts = append(opts, asynq.ProcessAt(*t.NextProcessAt))
+	case t.State == "archived":
+		opts = append(opts, asynq.ProcessAt(now.Add(importArchivedTaskDelay)))
+	}
+	return opts
+}
@@ -0,0 +1,83 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hibiken/asynq"
+)
+
+func TestExportedTaskRoundTrip(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	info := &asynq.TaskInfo{
+		ID:            "abc",
+		Queue:         "default",
+		Type:          "email:send",
+		Payload:       []byte(`{"to":"a@example.com"}`),
+		State:         asynq.TaskStateRetry,
+		MaxRetry:      5,
+		Retried:       2,
+		LastErr:       "smtp timeout",
+		LastFailedAt:  now.Add(-time.Minute),
+		Timeout:       30 * time.Second,
+		NextProcessAt: now.Add(time.Hour),
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(toExportedTask(info)); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	dec.DisallowUnknownFields()
+	var got exportedTask
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("could not decode exported task: %v", err)
+	}
+	if diff := cmp.Diff(toExportedTask(info), &got); diff != "" {
+		t.Errorf("exported task mismatch after round trip (-want,+got):\n%s", diff)
+	}
+}
+
+func TestImportTaskOptions(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	next := now.Add(time.Hour)
+	tests := []struct {
+		desc string
+		task exportedTask
+		want []asynq.Option
+	}{
+		{
+			desc: "Pending",
+			task: exportedTask{ID: "a", State: "pending", MaxRetry: 3},
+			want: []asynq.Option{asynq.Queue("q"), asynq.TaskID("a"), asynq.MaxRetry(3)},
+		},
+		{
+			desc: "Retry",
+			task: exportedTask{ID: "b", State: "retry", MaxRetry: 3, TimeoutSeconds: 30, NextProcessAt: &next},
+			want: []asynq.Option{asynq.Queue("q"), asynq.TaskID("b"), asynq.MaxRetry(3), asynq.Timeout(30 * time.Second), asynq.ProcessAt(next)},
+		},
+		{
+			desc: "Archived tasks are held in scheduled",
+			task: exportedTask{ID: "c", State: "archived", MaxRetry: 3},
+			want: []asynq.Option{asynq.Queue("q"), asynq.TaskID("c"), asynq.MaxRetry(3), asynq.ProcessAt(now.Add(importArchivedTaskDelay))},
+		},
+	}
+
+	for _, tc := range tests {
+		got := importTaskOptions("q", &tc.task, now)
+		if diff := cmp.Diff(optionStrings(tc.want), optionStrings(got)); diff != "" {
+			t.Errorf("%s: importTaskOptions mismatch (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func optionStrings(opts []asynq.Option) []string {
+	var out []string
+	for _, o := range opts {
+		out = append(out, o.String())
+	}
+	return out
+}
@@ -3,8 +3,14 @@ package asynqmon
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 
 	"github.com/hibiken/asynq"
@@ -15,29 +21,87 @@ import (
 //   - http.Handler(s) for queue related endpoints
 // ****************************************************************************
 
-func newListQueuesHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+// newListQueuesHandlerFunc returns a handler which lists the current state of all queues.
+// If cache is non-nil, the state is served from the cache when it is fresh, and
+// the response includes the time the state was cached at.
+//
+// Optional query params:
+// `pattern`: glob pattern to filter queues by name (e.g. "email-*"); see matchQueueName
+func newListQueuesHandlerFunc(inspector *asynq.Inspector, cache *queueStatsCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		qnames, err := inspector.Queues()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		inspector := traceInspector(r.Context(), inspector)
+		pattern := r.URL.Query().Get("pattern")
+		if !validQueueNamePattern(pattern) {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for pattern: %q", pattern))
 			return
 		}
-		snapshots := make([]*queueStateSnapshot, len(qnames))
-		for i, qname := range qnames {
-			qinfo, err := inspector.GetQueueInfo(qname)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+		payload := make(map[string]interface{})
+		var (
+			snapshots []*queueStateSnapshot
+			err       error
+		)
+		if cache == nil {
+			// Filter queues before fetching their state to avoid loading unneeded queues.
+			snapshots, err = fetchMatchingQueueStateSnapshots(inspector, pattern)
+		} else {
+			var (
+				cachedAt time.Time
+				ok       bool
+			)
+			if snapshots, cachedAt, ok = cache.get(); !ok {
+				snapshots, cachedAt, err = cache.refresh()
 			}
-			snapshots[i] = toQueueStateSnapshot(qinfo)
+			payload["cached_at"] = cachedAt
+			snapshots = filterQueueStateSnapshots(snapshots, pattern)
 		}
-		payload := map[string]interface{}{"queues": snapshots}
-		json.NewEncoder(w).Encode(payload)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		etagSrc := make([]*queueStateSnapshot, len(snapshots))
+		for i, s := range snapshots {
+			etagSrc[i] = snapshotForETag(s)
+		}
+		payload["queues"] = snapshots
+		writeResponseJSONWithETag(w, r, payload, etagSrc)
+	}
+}
+
+// matchQueueName reports whether the queue name matches the glob pattern.
+// The pattern syntax is that of path.Match: "*" matches any sequence of characters except "/",
+// "?" matches any single character, and "[...]" matches a character class.
+// A prefix can be matched with a trailing "*" (e.g. "email-*"). An empty pattern matches all queues.
+func matchQueueName(pattern, qname string) bool {
+	if pattern == "" {
+		return true
 	}
+	ok, err := path.Match(pattern, qname)
+	return err == nil && ok
+}
+
+// validQueueNamePattern reports whether pattern is a valid pattern for matchQueueName.
+func validQueueNamePattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// filterQueueStateSnapshots returns the snapshots of the queues whose name matches the pattern.
+func filterQueueStateSnapshots(snapshots []*queueStateSnapshot, pattern string) []*queueStateSnapshot {
+	if pattern == "" {
+		return snapshots
+	}
+	res := make([]*queueStateSnapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		if matchQueueName(pattern, s.Queue) {
+			res = append(res, s)
+		}
+	}
+	return res
 }
 
 func newGetQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 
@@ -45,7 +109,7 @@ func newGetQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
 			// TODO: Check for queue not found error.
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload["current"] = toQueueStateSnapshot(qinfo)
@@ -53,7 +117,7 @@ func newGetQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		// TODO: make this n a variable
 		data, err := inspector.History(qname, 10)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		var dailyStats []*dailyStats
@@ -67,30 +131,79 @@ func newGetQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 
 func newDeleteQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		if err := inspector.DeleteQueue(qname, false); err != nil {
 			if errors.Is(err, asynq.ErrQueueNotFound) {
-				http.Error(w, err.Error(), http.StatusNotFound)
+				respondError(w, http.StatusNotFound, errCodeNotFound, err.Error())
 				return
 			}
 			if errors.Is(err, asynq.ErrQueueNotEmpty) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 				return
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+type deleteQueuePreviewResponse struct {
+	Queue string `json:"queue"`
+	// Number of tasks in each state which deleting the queue would remove.
+	Pending   int `json:"pending"`
+	Active    int `json:"active"`
+	Scheduled int `json:"scheduled"`
+	Retry     int `json:"retry"`
+	Archived  int `json:"archived"`
+	// Total number of tasks which deleting the queue would remove.
+	Total int `json:"total"`
+	// RequiresForce indicates that the queue is not empty, so it can only be deleted with force.
+	RequiresForce bool `json:"requires_force"`
+	// Blocked indicates that the queue cannot be deleted even with force
+	// since it has active tasks.
+	Blocked bool `json:"blocked"`
+}
+
+// newDeleteQueuePreviewHandlerFunc returns a handler which reports what deleting a queue would remove,
+// without deleting anything. The counts are a snapshot and may change before the queue is deleted.
+func newDeleteQueuePreviewHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		qname := mux.Vars(r)["qname"]
+		info, err := inspector.GetQueueInfo(qname)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, err.Error())
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+		resp := deleteQueuePreviewResponse{
+			Queue:     info.Queue,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			Blocked:   info.Active > 0,
+		}
+		resp.Total = resp.Pending + resp.Active + resp.Scheduled + resp.Retry + resp.Archived
+		resp.RequiresForce = resp.Total > 0
+		writeResponseJSON(w, resp)
+	}
+}
+
 func newPauseQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		if err := inspector.PauseQueue(qname); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -99,25 +212,481 @@ func newPauseQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 
 func newResumeQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		if err := inspector.UnpauseQueue(qname); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// allQueuesKey is the redis key of the set of all queue names used by asynq.
+// This must be kept in sync with the keys defined in asynq's internal/base package.
+const allQueuesKey = "asynq:queues"
+
+// queueKeyPrefix returns the prefix of all redis keys used by asynq for the queue.
+// This must be kept in sync with the keys defined in asynq's internal/base package.
+func queueKeyPrefix(qname string) string {
+	return fmt.Sprintf("asynq:{%s}:", qname)
+}
+
+// KEYS[1] -> asynq:{<qname>}:pending
+// KEYS[2] -> asynq:{<qname>}:active
+// KEYS[3] -> asynq:{<qname>}:scheduled
+// KEYS[4] -> asynq:{<qname>}:retry
+// KEYS[5] -> asynq:{<qname>}:archived
+// KEYS[6] -> asynq:{<qname>}:completed
+// KEYS[7] -> asynq:{<qname>}:groups
+// ARGV[1] -> group key prefix (asynq:{<qname>}:g:)
+//
+// Returns the number of tasks in each state, with the number of aggregating tasks last.
+var queueSizeCmd = redis.NewScript(`
+local res = {
+	redis.call("LLEN", KEYS[1]),
+	redis.call("LLEN", KEYS[2]),
+	redis.call("ZCARD", KEYS[3]),
+	redis.call("ZCARD", KEYS[4]),
+	redis.call("ZCARD", KEYS[5]),
+	redis.call("ZCARD", KEYS[6]),
+}
+local aggregating = 0
+for _, gname in ipairs(redis.call("SMEMBERS", KEYS[7])) do
+	aggregating = aggregating + redis.call("ZCARD", ARGV[1] .. gname)
+end
+table.insert(res, aggregating)
+return res`)
+
+type queueSizeResponse struct {
+	Queue string `json:"queue"`
+	// Total number of tasks in the queue.
+	Size        int `json:"size"`
+	Pending     int `json:"pending"`
+	Active      int `json:"active"`
+	Scheduled   int `json:"scheduled"`
+	Retry       int `json:"retry"`
+	Archived    int `json:"archived"`
+	Completed   int `json:"completed"`
+	Aggregating int `json:"aggregating"`
+}
+
+// newGetQueueSizeHandlerFunc returns a handler which returns the number of tasks in a queue.
+// Unlike the queue info, this does not compute memory usage or daily stats, so it is cheap to poll.
+func newGetQueueSizeHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		qname := mux.Vars(r)["qname"]
+		exists, err := rc.SIsMember(ctx, allQueuesKey, qname).Result()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !exists {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+		prefix := queueKeyPrefix(qname)
+		keys := []string{
+			prefix + "pending",
+			prefix + "active",
+			prefix + "scheduled",
+			prefix + "retry",
+			prefix + "archived",
+			prefix + "completed",
+			prefix + "groups",
+		}
+		counts, err := queueSizeCmd.Run(ctx, rc, keys, prefix+"g:").Int64Slice()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		resp := queueSizeResponse{
+			Queue:       qname,
+			Pending:     int(counts[0]),
+			Active:      int(counts[1]),
+			Scheduled:   int(counts[2]),
+			Retry:       int(counts[3]),
+			Archived:    int(counts[4]),
+			Completed:   int(counts[5]),
+			Aggregating: int(counts[6]),
+		}
+		for _, n := range counts {
+			resp.Size += int(n)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// oldestTasksCmd returns the oldest task in the pending, scheduled, retry and archived states of a queue.
+//
+// KEYS[1] -> asynq:{<qname>}:pending
+// KEYS[2] -> asynq:{<qname>}:scheduled
+// KEYS[3] -> asynq:{<qname>}:retry
+// KEYS[4] -> asynq:{<qname>}:archived
+// ARGV[1] -> task key prefix (asynq:{<qname>}:t:)
+//
+// Returns the task ID and time of the oldest task for each state in order, or empty strings if the state is empty.
+// The time of the pending task is its pending_since field in unix nanoseconds, and the time of the
+// others is their score in unix seconds.
+var oldestTasksCmd = redis.NewScript(`
+local res = {}
+local id = redis.call("LINDEX", KEYS[1], -1)
+if id then
+	table.insert(res, id)
+	table.insert(res, redis.call("HGET", ARGV[1] .. id, "pending_since") or "")
+else
+	table.insert(res, "")
+	table.insert(res, "")
+end
+for i = 2, 4 do
+	local head = redis.call("ZRANGE", KEYS[i], 0, 0, "WITHSCORES")
+	table.insert(res, head[1] or "")
+	table.insert(res, head[2] or "")
+end
+return res`)
+
+type oldestTask struct {
+	TaskID string `json:"task_id"`
+	// Time the age is measured from.
+	Since time.Time `json:"since"`
+	// Age of the task in seconds.
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+type oldestTasksResponse struct {
+	Queue string `json:"queue"`
+	// Oldest task in each state; null if the state is empty.
+	// Pending tasks are aged from the time they became pending, scheduled and retry tasks
+	// from the time they were due to be processed (zero if not overdue yet), and archived
+	// tasks from the time they were archived.
+	Pending   *oldestTask `json:"pending"`
+	Scheduled *oldestTask `json:"scheduled"`
+	Retry     *oldestTask `json:"retry"`
+	Archived  *oldestTask `json:"archived"`
+}
+
+// newGetOldestTasksHandlerFunc returns a handler which returns the age of the oldest task
+// in each state of a queue, read from the head of the redis structure of each state.
+func newGetOldestTasksHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		qname := mux.Vars(r)["qname"]
+		exists, err := rc.SIsMember(ctx, allQueuesKey, qname).Result()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !exists {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+		prefix := queueKeyPrefix(qname)
+		keys := []string{
+			prefix + "pending",
+			prefix + "scheduled",
+			prefix + "retry",
+			prefix + "archived",
+		}
+		res, err := oldestTasksCmd.Run(ctx, rc, keys, prefix+"t:").StringSlice()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if len(res) != 8 {
+			writeInternalServerError(w, r, fmt.Errorf("unexpected number of values returned from redis: %d", len(res)))
+			return
+		}
+		now := time.Now()
+		resp := oldestTasksResponse{
+			Queue:     qname,
+			Pending:   toOldestTask(res[0], res[1], time.Nanosecond, now),
+			Scheduled: toOldestTask(res[2], res[3], time.Second, now),
+			Retry:     toOldestTask(res[4], res[5], time.Second, now),
+			Archived:  toOldestTask(res[6], res[7], time.Second, now),
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// toOldestTask returns the oldest task given its ID and time in the given unit since the unix epoch,
+// or nil if id is empty. If the time is missing or in the future, the age is zero.
+func toOldestTask(id, since string, unit time.Duration, now time.Time) *oldestTask {
+	if id == "" {
+		return nil
+	}
+	t := &oldestTask{TaskID: id}
+	n, err := strconv.ParseFloat(since, 64)
+	if err != nil {
+		return t
+	}
+	t.Since = time.Unix(0, int64(n*float64(unit))).UTC()
+	if age := now.Sub(t.Since); age > 0 {
+		t.AgeSeconds = age.Seconds()
+	}
+	return t
+}
+
+// statsTTL is the duration asynq keeps daily stats for.
+// It must match the TTL used by asynq when it creates the daily stats keys.
+const statsTTL = 90 * 24 * time.Hour
+
+// recordDailyStatsCmd makes sure the daily stats keys of a queue exist for the day,
+// without changing the counts recorded by asynq.
+//
+// The keys use the same format as asynq's own recorder: the counters are plain integer
+// strings which asynq increments with INCR as tasks are processed, and asynq sets the
+// expiration only when it creates a key. So a key created here is given the same expiration
+// asynq would have set.
+//
+// KEYS[1] -> asynq:{<qname>}:processed:<yyyy-mm-dd>
+// KEYS[2] -> asynq:{<qname>}:failed:<yyyy-mm-dd>
+// ARGV[1] -> stats expiration timestamp
+//
+// Returns the processed and failed counts, and the number of keys created.
+var recordDailyStatsCmd = redis.NewScript(`
+local res = {}
+local created = 0
+for i = 1, 2 do
+	if redis.call("SETNX", KEYS[i], 0) == 1 then
+		redis.call("EXPIREAT", KEYS[i], ARGV[1])
+		created = created + 1
+	end
+	table.insert(res, tonumber(redis.call("GET", KEYS[i])))
+end
+table.insert(res, created)
+return res`)
+
+type statsSnapshotResponse struct {
+	Queue string `json:"queue"`
+	// Date of the daily stats in "YYYY-MM-DD" format (UTC).
+	Date      string `json:"date"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+	// Created indicates that the daily stats keys did not exist and were created.
+	Created bool `json:"created"`
+}
+
+// newRecordStatsSnapshotHandlerFunc returns a handler which records today's daily stats of a queue,
+// so that historical stats have a data point even if the queue has not processed any tasks today.
+// Counts already recorded by asynq are left unchanged.
+func newRecordStatsSnapshotHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		qname := mux.Vars(r)["qname"]
+		exists, err := rc.SIsMember(ctx, allQueuesKey, qname).Result()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !exists {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+		now := time.Now().UTC()
+		date := now.Format("2006-01-02")
+		prefix := queueKeyPrefix(qname)
+		keys := []string{
+			prefix + "processed:" + date,
+			prefix + "failed:" + date,
+		}
+		res, err := recordDailyStatsCmd.Run(ctx, rc, keys, now.Add(statsTTL).Unix()).Int64Slice()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		resp := statsSnapshotResponse{
+			Queue:     qname,
+			Date:      date,
+			Processed: int(res[0]),
+			Failed:    int(res[1]),
+			Created:   res[2] > 0,
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// Health status of a queue.
+const (
+	queueHealthOK       = "ok"
+	queueHealthDegraded = "degraded"
+	queueHealthCritical = "critical"
+)
+
+// queueHealthWarningRatio is the ratio of a threshold above which a metric is considered degraded.
+const queueHealthWarningRatio = 0.8
+
+type queueHealthReason struct {
+	// Name of the metric: "latency_seconds", "size" or "failed".
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// "degraded" if the value is close to the threshold, "critical" if it exceeds the threshold.
+	Status string `json:"status"`
+}
+
+type queueHealthResponse struct {
+	Queue   string               `json:"queue"`
+	Status  string               `json:"status"`
+	Reasons []*queueHealthReason `json:"reasons"`
+}
+
+// newGetQueueHealthHandlerFunc returns a handler which evaluates the queue against the thresholds
+// given by the max_latency, max_size and max_failed (tasks failed today) query params.
+// The queue is critical if any metric exceeds its threshold, and degraded if any metric exceeds
+// 80% of its threshold. The handler responds with 503 if the queue is critical.
+func newGetQueueHealthHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		q := r.URL.Query()
+		thresholds := make(map[string]float64)
+		if v := q.Get("max_latency"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for max_latency: %q", v))
+				return
+			}
+			thresholds["latency_seconds"] = d.Seconds()
+		}
+		for _, x := range []struct{ param, metric string }{{"max_size", "size"}, {"max_failed", "failed"}} {
+			if v := q.Get(x.param); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for %s: %q", x.param, v))
+					return
+				}
+				thresholds[x.metric] = float64(n)
+			}
+		}
+
+		qname := mux.Vars(r)["qname"]
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !contains(qnames, qname) {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+		qinfo, err := inspector.GetQueueInfo(qname)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+
+		resp := queueHealthResponse{
+			Queue:   qname,
+			Status:  queueHealthOK,
+			Reasons: make([]*queueHealthReason, 0), // avoid null in the json response
+		}
+		for _, m := range []struct {
+			metric string
+			value  float64
+		}{
+			{"latency_seconds", qinfo.Latency.Seconds()},
+			{"size", float64(qinfo.Size)},
+			{"failed", float64(qinfo.Failed)},
+		} {
+			threshold, ok := thresholds[m.metric]
+			if !ok {
+				continue
+			}
+			var status string
+			switch {
+			case m.value > threshold:
+				status = queueHealthCritical
+			case m.value > threshold*queueHealthWarningRatio:
+				status = queueHealthDegraded
+			default:
+				continue
+			}
+			resp.Reasons = append(resp.Reasons, &queueHealthReason{
+				Metric:    m.metric,
+				Value:     m.value,
+				Threshold: threshold,
+				Status:    status,
+			})
+			if status == queueHealthCritical || resp.Status == queueHealthOK {
+				resp.Status = status
+			}
+		}
+		if resp.Status == queueHealthCritical {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type updateQueueRequest struct {
+	Paused *bool `json:"paused"`
+}
+
+// newUpdateQueueHandlerFunc returns a handler which sets the paused state of a queue
+// and returns the resulting state of the queue.
+// Setting a queue to the state it is already in is not an error.
+func newUpdateQueueHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req updateQueueRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if req.Paused == nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "paused is required")
+			return
+		}
+
+		qname := mux.Vars(r)["qname"]
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !contains(qnames, qname) {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+		qinfo, err := inspector.GetQueueInfo(qname)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if qinfo.Paused != *req.Paused {
+			if *req.Paused {
+				err = inspector.PauseQueue(qname)
+			} else {
+				err = inspector.UnpauseQueue(qname)
+			}
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			if qinfo, err = inspector.GetQueueInfo(qname); err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+		}
+		writeResponseJSON(w, toQueueStateSnapshot(qinfo))
+	}
+}
+
 type listQueueStatsResponse struct {
 	Stats map[string][]*dailyStats `json:"stats"`
 }
 
 func newListQueueStatsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qnames, err := inspector.Queues()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		resp := listQueueStatsResponse{Stats: make(map[string][]*dailyStats)}
@@ -125,14 +694,137 @@ func newListQueueStatsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		for _, qname := range qnames {
 			stats, err := inspector.History(qname, numdays)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeInternalServerError(w, r, err)
 				return
 			}
 			resp.Stats[qname] = toDailyStatsList(stats)
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
+			return
+		}
+	}
+}
+
+type compareQueuesResponse struct {
+	A *queueStateSnapshot `json:"a"`
+	B *queueStateSnapshot `json:"b"`
+}
+
+// newCompareQueuesHandlerFunc returns a handler which returns the current state of two queues
+// specified by the `a` and `b` query params.
+func newCompareQueuesHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		q := r.URL.Query()
+		a, b := q.Get("a"), q.Get("b")
+		if a == "" || b == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "query params a and b are required")
 			return
 		}
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		var resp compareQueuesResponse
+		for _, x := range []struct {
+			qname string
+			dst   **queueStateSnapshot
+		}{{a, &resp.A}, {b, &resp.B}} {
+			if !contains(qnames, x.qname) {
+				respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", x.qname))
+				return
+			}
+			qinfo, err := inspector.GetQueueInfo(x.qname)
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			*x.dst = toQueueStateSnapshot(qinfo)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type serverPriority struct {
+	ServerID       string `json:"server_id"`
+	Host           string `json:"host"`
+	PID            int    `json:"pid"`
+	Priority       int    `json:"priority"`
+	StrictPriority bool   `json:"strict_priority"`
+}
+
+type queuePriorities struct {
+	Queue string `json:"queue"`
+	// Priorities assigned to the queue by each server processing it.
+	Servers []*serverPriority `json:"servers"`
+	// Consistent is false if servers disagree on the priority of the queue.
+	Consistent bool `json:"consistent"`
+}
+
+type listQueuePrioritiesResponse struct {
+	Queues []*queuePriorities `json:"queues"`
+}
+
+// newListQueuePrioritiesHandlerFunc returns a handler which lists the priority assigned to each queue
+// by each active server. Queues which no server processes are listed with no servers.
+func newListQueuePrioritiesHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		srvs, err := inspector.Servers()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		m := make(map[string]*queuePriorities)
+		for _, qname := range qnames {
+			m[qname] = &queuePriorities{Queue: qname, Servers: make([]*serverPriority, 0)}
+		}
+		for _, srv := range srvs {
+			for qname, priority := range srv.Queues {
+				qp, ok := m[qname]
+				if !ok {
+					qp = &queuePriorities{Queue: qname, Servers: make([]*serverPriority, 0)}
+					m[qname] = qp
+				}
+				qp.Servers = append(qp.Servers, &serverPriority{
+					ServerID:       srv.ID,
+					Host:           srv.Host,
+					PID:            srv.PID,
+					Priority:       priority,
+					StrictPriority: srv.StrictPriority,
+				})
+			}
+		}
+		resp := listQueuePrioritiesResponse{Queues: make([]*queuePriorities, 0, len(m))}
+		for _, qp := range m {
+			sort.Slice(qp.Servers, func(i, j int) bool { return qp.Servers[i].ServerID < qp.Servers[j].ServerID })
+			qp.Consistent = true
+			for _, sp := range qp.Servers {
+				if sp.Priority != qp.Servers[0].Priority || sp.StrictPriority != qp.Servers[0].StrictPriority {
+					qp.Consistent = false
+					break
+				}
+			}
+			resp.Queues = append(resp.Queues, qp)
+		}
+		sort.Slice(resp.Queues, func(i, j int) bool { return resp.Queues[i].Queue < resp.Queues[j].Queue })
+		writeResponseJSON(w, resp)
+	}
+}
+
+// contains reports whether the slice contains the string s.
+func contains(slice []string, s string) bool {
+	for _, x := range slice {
+		if x == s {
+			return true
+		}
 	}
+	return false
 }
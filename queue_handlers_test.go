@@ -0,0 +1,60 @@
+package asynqmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchQueueName(t *testing.T) {
+	tests := []struct {
+		pattern string
+		qname   string
+		want    bool
+	}{
+		{"", "default", true},
+		{"default", "default", true},
+		{"email-*", "email-high", true},
+		{"email-*", "sms-high", false},
+		{"*-high", "email-high", true},
+		{"email-?", "email-1", true},
+		{"email-[ab]", "email-c", false},
+		{"[", "default", false},
+	}
+
+	for _, tc := range tests {
+		if got := matchQueueName(tc.pattern, tc.qname); got != tc.want {
+			t.Errorf("matchQueueName(%q, %q) = %t, want %t", tc.pattern, tc.qname, got, tc.want)
+		}
+	}
+
+	if validQueueNamePattern("email-[") {
+		t.Errorf("validQueueNamePattern(%q) = true, want false", "email-[")
+	}
+}
+
+func TestToOldestTask(t *testing.T) {
+	now := time.Unix(1700000100, 0)
+
+	if got := toOldestTask("", "", time.Second, now); got != nil {
+		t.Errorf("toOldestTask with empty id = %+v, want nil", got)
+	}
+
+	got := toOldestTask("abc", "1700000000", time.Second, now)
+	want := &oldestTask{TaskID: "abc", Since: time.Unix(1700000000, 0).UTC(), AgeSeconds: 100}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("toOldestTask returned %+v, want %+v; (-want,+got)\n%s", got, want, diff)
+	}
+
+	got = toOldestTask("abc", "1700000000000000000", time.Nanosecond, now)
+	if got.AgeSeconds != 100 {
+		t.Errorf("toOldestTask with nanoseconds returned age %v, want 100", got.AgeSeconds)
+	}
+
+	// Scheduled tasks due in the future are not overdue yet.
+	got = toOldestTask("abc", "1700000200", time.Second, now)
+	if got.AgeSeconds != 0 {
+		t.Errorf("toOldestTask with future time returned age %v, want 0", got.AgeSeconds)
+	}
+}
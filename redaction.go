@@ -0,0 +1,98 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - PayloadFormatter which redacts sensitive fields in payloads
+// ****************************************************************************
+
+// redactedValue replaces the values of redacted payload fields.
+const redactedValue = "***"
+
+// redactingPayloadFormatter is a PayloadFormatter which redacts the configured fields
+// in JSON payloads before passing the payload to the underlying formatter.
+type redactingPayloadFormatter struct {
+	pf PayloadFormatter
+
+	// paths maps task type to the list of field paths to redact.
+	// Each path is a list of object keys from the top-level object to the field.
+	paths map[string][][]string
+}
+
+// newRedactingPayloadFormatter returns a PayloadFormatter which redacts the fields
+// specified by rules. rules maps task type to a list of dot-separated field paths (e.g. "user.email").
+func newRedactingPayloadFormatter(pf PayloadFormatter, rules map[string][]string) *redactingPayloadFormatter {
+	paths := make(map[string][][]string)
+	for taskType, fields := range rules {
+		for _, f := range fields {
+			paths[taskType] = append(paths[taskType], strings.Split(f, "."))
+		}
+	}
+	return &redactingPayloadFormatter{pf: pf, paths: paths}
+}
+
+func (f *redactingPayloadFormatter) FormatPayload(taskType string, payload []byte) string {
+	return f.pf.FormatPayload(taskType, f.redact(taskType, payload))
+}
+
+// redact returns the payload with the values of the fields configured for the task type replaced.
+// If the payload is not a JSON object, or none of the fields are found, the payload is returned unchanged.
+func (f *redactingPayloadFormatter) redact(taskType string, payload []byte) []byte {
+	paths, ok := f.paths[taskType]
+	if !ok {
+		return payload
+	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber() // preserve numbers as is
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return payload
+	}
+	redacted := false
+	for _, p := range paths {
+		if redactPath(v, p) {
+			redacted = true
+		}
+	}
+	if !redacted {
+		return payload
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return payload
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// redactPath replaces the value found at path in v and reports whether any value was replaced.
+// If an array is found along the path, the rest of the path is applied to each element.
+func redactPath(v interface{}, path []string) bool {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		child, ok := x[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			x[path[0]] = redactedValue
+			return true
+		}
+		return redactPath(child, path[1:])
+	case []interface{}:
+		redacted := false
+		for _, elem := range x {
+			if redactPath(elem, path) {
+				redacted = true
+			}
+		}
+		return redacted
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package asynqmon
+
+import (
+	"testing"
+)
+
+func TestRedactingPayloadFormatter(t *testing.T) {
+	pf := newRedactingPayloadFormatter(DefaultPayloadFormatter, map[string][]string{
+		"email:send": {"to", "user.ssn", "attachments.key"},
+	})
+
+	tests := []struct {
+		desc     string
+		taskType string
+		payload  string
+		want     string
+	}{
+		{
+			desc:     "Top-level and nested fields",
+			taskType: "email:send",
+			payload:  `{"to":"user@example.com","user":{"id":42,"ssn":"123-45-6789"}}`,
+			want:     `{"to":"***","user":{"id":42,"ssn":"***"}}`,
+		},
+		{
+			desc:     "Fields in array elements",
+			taskType: "email:send",
+			payload:  `{"attachments":[{"key":"a","name":"x"},{"key":"b"}]}`,
+			want:     `{"attachments":[{"key":"***","name":"x"},{"key":"***"}]}`,
+		},
+		{
+			desc:     "No matching fields",
+			taskType: "email:send",
+			payload:  `{"subject": "hello",  "body": "<p>hi</p>"}`,
+			want:     `{"subject": "hello",  "body": "<p>hi</p>"}`,
+		},
+		{
+			desc:     "Task type without rules",
+			taskType: "image:resize",
+			payload:  `{"to":"user@example.com"}`,
+			want:     `{"to":"user@example.com"}`,
+		},
+		{
+			desc:     "Non JSON payload",
+			taskType: "email:send",
+			payload:  `to=user@example.com`,
+			want:     `to=user@example.com`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := pf.FormatPayload(tc.taskType, []byte(tc.payload))
+			if got != tc.want {
+				t.Errorf("FormatPayload(%q, %q) = %q, want %q", tc.taskType, tc.payload, got, tc.want)
+			}
+		})
+	}
+}
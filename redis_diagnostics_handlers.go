@@ -0,0 +1,193 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for redis diagnostics endpoints
+// ****************************************************************************
+
+// Default and maximum number of slowlog entries returned per redis node.
+// The maximum matches the default value of the slowlog-max-len config.
+const (
+	defaultSlowlogCount = 10
+	maxSlowlogCount     = 128
+)
+
+type slowlogEntry struct {
+	ID int64 `json:"id"`
+	// Address of the redis node which logged the command.
+	Node      string    `json:"node"`
+	Timestamp time.Time `json:"timestamp"`
+	// Execution time of the command in microseconds.
+	DurationMicros int64  `json:"duration_us"`
+	Command        string `json:"command"`
+	ClientAddr     string `json:"client_addr"`
+	ClientName     string `json:"client_name"`
+}
+
+type slowlogResponse struct {
+	// Slow commands sorted by timestamp in descending order.
+	// Empty if no command exceeded the slowlog-log-slower-than threshold.
+	Entries []*slowlogEntry `json:"entries"`
+}
+
+// newSlowlogHandlerFunc returns a handler which returns the recent slow commands
+// logged by redis (SLOWLOG GET). When connected to a redis cluster, entries of every
+// master node are returned.
+//
+// Optional query params:
+// `count`: maximum number of entries to return per node (default 10, max 128)
+func newSlowlogHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		count := defaultSlowlogCount
+		if s := r.URL.Query().Get("count"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for count: %q", s))
+				return
+			}
+			count = n
+		}
+		if count > maxSlowlogCount {
+			count = maxSlowlogCount
+		}
+
+		var mu sync.Mutex
+		entries := make([]*slowlogEntry, 0)
+		err := forEachRedisNode(r.Context(), rc, func(ctx context.Context, c *redis.Client) error {
+			cmd := redis.NewSlowLogCmd(ctx, "slowlog", "get", count)
+			if err := c.Process(ctx, cmd); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, l := range cmd.Val() {
+				entries = append(entries, &slowlogEntry{
+					ID:             l.ID,
+					Node:           c.Options().Addr,
+					Timestamp:      l.Time,
+					DurationMicros: l.Duration.Microseconds(),
+					Command:        strings.Join(l.Args, " "),
+					ClientAddr:     l.ClientAddr,
+					ClientName:     l.ClientName,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Timestamp.After(entries[j].Timestamp)
+		})
+		writeResponseJSON(w, slowlogResponse{Entries: entries})
+	}
+}
+
+type latencyEvent struct {
+	// Address of the redis node which reported the event.
+	Node  string `json:"node"`
+	Event string `json:"event"`
+	// Time of the latest latency spike of the event.
+	Timestamp time.Time `json:"timestamp"`
+	// Latest and all-time maximum latency of the event in milliseconds.
+	LatestMillis int64 `json:"latest_ms"`
+	MaxMillis    int64 `json:"max_ms"`
+}
+
+type latencyResponse struct {
+	// Empty if no latency spike was recorded, or the latency monitor is disabled
+	// (i.e. latency-monitor-threshold is 0).
+	Events []*latencyEvent `json:"events"`
+}
+
+// newLatencyHandlerFunc returns a handler which returns the latest latency spikes
+// recorded by the redis latency monitor (LATENCY LATEST).
+// When connected to a redis cluster, events of every master node are returned.
+func newLatencyHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var mu sync.Mutex
+		events := make([]*latencyEvent, 0)
+		err := forEachRedisNode(r.Context(), rc, func(ctx context.Context, c *redis.Client) error {
+			res, err := c.Do(ctx, "latency", "latest").Slice()
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, v := range res {
+				e, err := parseLatencyEvent(v)
+				if err != nil {
+					return err
+				}
+				e.Node = c.Options().Addr
+				events = append(events, e)
+			}
+			return nil
+		})
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		sort.Slice(events, func(i, j int) bool {
+			if events[i].Node != events[j].Node {
+				return events[i].Node < events[j].Node
+			}
+			return events[i].Event < events[j].Event
+		})
+		writeResponseJSON(w, latencyResponse{Events: events})
+	}
+}
+
+// parseLatencyEvent parses an element of the LATENCY LATEST reply,
+// which is an array of event name, unix timestamp, latest and max latency.
+// See https://redis.io/commands/latency-latest.
+func parseLatencyEvent(v interface{}) (*latencyEvent, error) {
+	fields, ok := v.([]interface{})
+	if !ok || len(fields) < 4 {
+		return nil, fmt.Errorf("unexpected LATENCY LATEST reply: %v", v)
+	}
+	event, ok := fields[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected LATENCY LATEST reply: %v", v)
+	}
+	var nums [3]int64
+	for i := range nums {
+		n, ok := fields[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected LATENCY LATEST reply: %v", v)
+		}
+		nums[i] = n
+	}
+	return &latencyEvent{
+		Event:        event,
+		Timestamp:    time.Unix(nums[0], 0).UTC(),
+		LatestMillis: nums[1],
+		MaxMillis:    nums[2],
+	}, nil
+}
+
+// forEachRedisNode calls fn with the client of each redis node.
+// When connected to a redis cluster, fn is called concurrently for each master node.
+func forEachRedisNode(ctx context.Context, rc redis.UniversalClient, fn func(ctx context.Context, c *redis.Client) error) error {
+	switch c := rc.(type) {
+	case *redis.ClusterClient:
+		return c.ForEachMaster(ctx, fn)
+	case *redis.Client:
+		return fn(ctx, c)
+	}
+	return fmt.Errorf("unsupported redis client type %T", rc)
+}
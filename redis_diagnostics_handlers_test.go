@@ -0,0 +1,35 @@
+package asynqmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLatencyEvent(t *testing.T) {
+	got, err := parseLatencyEvent([]interface{}{"command", int64(1700000000), int64(12), int64(250)})
+	if err != nil {
+		t.Fatalf("parseLatencyEvent returned error: %v", err)
+	}
+	want := &latencyEvent{
+		Event:        "command",
+		Timestamp:    time.Unix(1700000000, 0).UTC(),
+		LatestMillis: 12,
+		MaxMillis:    250,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseLatencyEvent returned %+v, want %+v; (-want,+got)\n%s", got, want, diff)
+	}
+
+	for _, v := range []interface{}{
+		"command",
+		[]interface{}{"command", int64(1700000000)},
+		[]interface{}{int64(1), int64(1700000000), int64(12), int64(250)},
+		[]interface{}{"command", "1700000000", int64(12), int64(250)},
+	} {
+		if _, err := parseLatencyEvent(v); err == nil {
+			t.Errorf("parseLatencyEvent(%v) returned nil error, want non-nil", v)
+		}
+	}
+}
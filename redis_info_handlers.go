@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/go-redis/redis/v8"
@@ -35,9 +36,9 @@ type queueLocationInfo struct {
 
 func newRedisInfoHandlerFunc(client *redis.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		res, err := client.Info(context.Background()).Result()
+		res, err := client.Info(r.Context()).Result()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		info := parseRedisInfo(res)
@@ -48,7 +49,7 @@ func newRedisInfoHandlerFunc(client *redis.Client) http.HandlerFunc {
 			Cluster: false,
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 	}
@@ -56,21 +57,22 @@ func newRedisInfoHandlerFunc(client *redis.Client) http.HandlerFunc {
 
 func newRedisClusterInfoHandlerFunc(client *redis.ClusterClient, inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
+		inspector := traceInspector(r.Context(), inspector)
+		ctx := r.Context()
 		rawClusterInfo, err := client.ClusterInfo(ctx).Result()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		info := parseRedisInfo(rawClusterInfo)
 		rawClusterNodes, err := client.ClusterNodes(ctx).Result()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		queues, err := inspector.Queues()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		var queueLocations []*queueLocationInfo
@@ -78,12 +80,12 @@ func newRedisClusterInfoHandlerFunc(client *redis.ClusterClient, inspector *asyn
 			q := queueLocationInfo{Queue: qname}
 			q.KeySlot, err = inspector.ClusterKeySlot(qname)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeInternalServerError(w, r, err)
 				return
 			}
 			nodes, err := inspector.ClusterNodes(qname)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeInternalServerError(w, r, err)
 				return
 			}
 			for _, n := range nodes {
@@ -101,7 +103,7 @@ func newRedisClusterInfoHandlerFunc(client *redis.ClusterClient, inspector *asyn
 			QueueLocations:  queueLocations,
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 	}
@@ -121,3 +123,168 @@ func parseRedisInfo(infoStr string) map[string]string {
 	return info
 
 }
+
+// Maximum number of keys scanned per queue to count the keys of a queue.
+const maxQueueUsageScan = 10000
+
+// Maximum number of SCAN calls per queue to count the keys of a queue.
+// SCAN walks the keys of the whole database, so a small queue in a large database would
+// otherwise take one call per queueUsageScanCount keys in the database, however few keys match.
+const maxQueueUsageScanCalls = 100
+
+// Number of keys examined by a single SCAN call.
+const queueUsageScanCount = 1000
+
+// Number of task keys sampled per queue to estimate the memory used by tasks.
+const queueUsageTaskSamples = 100
+
+// queueStructures lists the redis data structures used by asynq for each queue,
+// keyed by the suffix of the key.
+var queueStructures = []struct {
+	name string
+	typ  string
+}{
+	{"pending", "list"},
+	{"active", "list"},
+	{"scheduled", "zset"},
+	{"retry", "zset"},
+	{"archived", "zset"},
+	{"completed", "zset"},
+	{"lease", "zset"},
+	{"groups", "set"},
+}
+
+type redisKeyUsage struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Number of elements in the data structure.
+	Length int64 `json:"length"`
+	// Memory used by the key in bytes, as reported by MEMORY USAGE.
+	MemoryUsage int64 `json:"memory_usage_bytes"`
+}
+
+type queueUsage struct {
+	Queue      string           `json:"queue"`
+	Structures []*redisKeyUsage `json:"structures"`
+	// Number of keys of the queue, including task keys.
+	KeyCount int `json:"key_count"`
+	// KeyCountTruncated is true if the scan stopped before all keys were counted.
+	KeyCountTruncated bool `json:"key_count_truncated"`
+	// Estimated memory used by task keys in bytes, extrapolated from sampled task keys.
+	TasksMemoryUsage int64 `json:"tasks_memory_usage_bytes"`
+	// Estimated total memory used by the queue in bytes.
+	MemoryUsage int64 `json:"memory_usage_bytes"`
+}
+
+type queueUsageResponse struct {
+	Queues []*queueUsage `json:"queues"`
+}
+
+// newQueueUsageHandlerFunc returns a handler which reports the keys and memory used by each queue in redis.
+// Memory figures are approximate: MEMORY USAGE samples nested elements, and the memory used by tasks
+// is extrapolated from a sample of task keys.
+func newQueueUsageHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		qnames, err := rc.SMembers(ctx, allQueuesKey).Result()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		sort.Strings(qnames)
+		resp := queueUsageResponse{Queues: make([]*queueUsage, 0, len(qnames))}
+		for _, qname := range qnames {
+			u, err := getQueueUsage(ctx, rc, qname)
+			if err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+			resp.Queues = append(resp.Queues, u)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+func getQueueUsage(ctx context.Context, rc redis.UniversalClient, qname string) (*queueUsage, error) {
+	prefix := queueKeyPrefix(qname)
+	u := &queueUsage{Queue: qname, Structures: make([]*redisKeyUsage, 0, len(queueStructures))}
+	var numTasks int64
+	for _, s := range queueStructures {
+		key := prefix + s.name
+		var n int64
+		var err error
+		switch s.typ {
+		case "list":
+			n, err = rc.LLen(ctx, key).Result()
+		case "zset":
+			n, err = rc.ZCard(ctx, key).Result()
+		case "set":
+			n, err = rc.SCard(ctx, key).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		mem, err := memoryUsage(ctx, rc, key)
+		if err != nil {
+			return nil, err
+		}
+		u.Structures = append(u.Structures, &redisKeyUsage{Name: s.name, Key: key, Length: n, MemoryUsage: mem})
+		u.MemoryUsage += mem
+		if s.name != "lease" && s.name != "groups" {
+			numTasks += n
+		}
+	}
+
+	// All keys of a queue are in the same hash slot, so they can be scanned on a single node.
+	scanner := rc
+	if cc, ok := rc.(*redis.ClusterClient); ok {
+		node, err := cc.MasterForKey(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		scanner = node
+	}
+	var (
+		cursor     uint64
+		sampled    int
+		sampledMem int64
+	)
+	for calls := 1; ; calls++ {
+		keys, next, err := scanner.Scan(ctx, cursor, prefix+"*", queueUsageScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			u.KeyCount++
+			if sampled < queueUsageTaskSamples && strings.HasPrefix(key, prefix+"t:") {
+				mem, err := memoryUsage(ctx, rc, key)
+				if err != nil {
+					return nil, err
+				}
+				sampled++
+				sampledMem += mem
+			}
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+		if u.KeyCount >= maxQueueUsageScan || calls >= maxQueueUsageScanCalls {
+			u.KeyCountTruncated = true
+			break
+		}
+	}
+	if sampled > 0 {
+		u.TasksMemoryUsage = sampledMem * numTasks / int64(sampled)
+		u.MemoryUsage += u.TasksMemoryUsage
+	}
+	return u, nil
+}
+
+// memoryUsage returns the memory used by the key in bytes, or zero if the key does not exist.
+func memoryUsage(ctx context.Context, rc redis.UniversalClient, key string) (int64, error) {
+	n, err := rc.MemoryUsage(ctx, key).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - middleware to propagate request ID
+// ****************************************************************************
+
+// RequestIDHeader is the header used to receive and echo back the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// Maximum length of a request ID accepted from the client.
+const maxRequestIDLen = 128
+
+type requestIDContextKey struct{}
+
+// RequestIDFromContext returns the request ID stored in ctx by RequestIDMiddleware.
+// It returns an empty string if ctx has no request ID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware is a middleware which reads the request ID from the X-Request-ID header,
+// or generates a new one if the header is absent or invalid, and stores it in the request context.
+// The request ID is echoed back in the X-Request-ID response header.
+//
+// If the request context already has a request ID, the middleware uses it as is.
+func RequestIDMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := RequestIDFromContext(r.Context())
+		if id == "" {
+			id = r.Header.Get(RequestIDHeader)
+			if !isValidRequestID(id) {
+				id = uuid.NewString()
+			}
+			r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id))
+		}
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isValidRequestID reports whether id is non-empty, not too long and comprised of
+// printable ASCII characters other than space, so that it's safe to write to logs.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// logRequestf writes a log prefixed with the ID of the request r.
+func logRequestf(r *http.Request, format string, args ...interface{}) {
+	log.Printf("[request_id=%s] %s", RequestIDFromContext(r.Context()), fmt.Sprintf(format, args...))
+}
@@ -2,6 +2,7 @@ package asynqmon
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -16,9 +17,10 @@ import (
 
 func newListSchedulerEntriesHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		entries, err := inspector.SchedulerEntries()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -29,31 +31,51 @@ func newListSchedulerEntriesHandlerFunc(inspector *asynq.Inspector, pf PayloadFo
 			payload["entries"] = toSchedulerEntries(entries, pf)
 		}
 		if err := json.NewEncoder(w).Encode(payload); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 	}
 }
 
+func newGetSchedulerEntryHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		entryID := mux.Vars(r)["entry_id"]
+		entries, err := inspector.SchedulerEntries()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		for _, e := range entries {
+			if e.ID == entryID {
+				writeResponseJSON(w, toSchedulerEntry(e, pf))
+				return
+			}
+		}
+		respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("scheduler entry %q not found", entryID))
+	}
+}
+
 type listSchedulerEnqueueEventsResponse struct {
 	Events []*schedulerEnqueueEvent `json:"events"`
 }
 
 func newListSchedulerEnqueueEventsHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		entryID := mux.Vars(r)["entry_id"]
 		pageSize, pageNum := getPageOptions(r)
 		events, err := inspector.ListSchedulerEnqueueEvents(
 			entryID, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		resp := listSchedulerEnqueueEventsResponse{
 			Events: toSchedulerEnqueueEvents(events),
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 	}
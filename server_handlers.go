@@ -3,6 +3,11 @@ package asynqmon
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
 
 	"github.com/hibiken/asynq"
 )
@@ -18,17 +23,77 @@ type listServersResponse struct {
 
 func newListServersHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		srvs, err := inspector.Servers()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		resp := listServersResponse{
 			Servers: toServerInfoList(srvs, pf),
 		}
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 	}
 }
+
+// Redis keys used by asynq to keep track of servers.
+// These must be kept in sync with the keys defined in asynq's internal/base package.
+const (
+	allServersKey       = "asynq:servers" // ZSET of server info keys scored by expiration time
+	allWorkersKey       = "asynq:workers" // ZSET of workers keys scored by expiration time
+	serverInfoKeyPrefix = "asynq:servers:"
+	workersKeyPrefix    = "asynq:workers:"
+)
+
+// serverExpirationGracePeriod is the duration to wait after a server's heartbeat expiration
+// before treating it as stale. This tolerates clock skew between asynq servers and asynqmon.
+const serverExpirationGracePeriod = time.Minute
+
+// KEYS[1] -> asynq:servers or asynq:workers
+// ARGV[1] -> cutoff time in unix seconds
+//
+// Removes and returns members expired before the cutoff time.
+// Running as a script ensures that a member refreshed by a live server
+// in the meantime is not removed.
+var pruneExpiredMembersCmd = redis.NewScript(`
+local members = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[1])
+for _, m in ipairs(members) do
+	redis.call("ZREM", KEYS[1], m)
+end
+return members`)
+
+type pruneServersResponse struct {
+	// Number of stale servers removed.
+	Pruned int `json:"pruned"`
+}
+
+// newPruneServersHandlerFunc returns a handler which removes servers whose heartbeat
+// has expired from redis. Servers which are still sending heartbeats are never removed.
+func newPruneServersHandlerFunc(rc redis.UniversalClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cutoff := strconv.FormatInt(time.Now().Add(-serverExpirationGracePeriod).Unix(), 10)
+		skeys, err := pruneExpiredMembersCmd.Run(ctx, rc, []string{allServersKey}, cutoff).StringSlice()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if err := pruneExpiredMembersCmd.Run(ctx, rc, []string{allWorkersKey}, cutoff).Err(); err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		// Delete the data left behind by the stale servers.
+		// Server info key and workers key share the same hash tag, so they can be deleted together in redis cluster.
+		for _, skey := range skeys {
+			wkey := workersKeyPrefix + strings.TrimPrefix(skey, serverInfoKeyPrefix)
+			if err := rc.Del(ctx, skey, wkey).Err(); err != nil {
+				writeInternalServerError(w, r, err)
+				return
+			}
+		}
+		writeResponseJSON(w, pruneServersResponse{Pruned: len(skeys)})
+	}
+}
@@ -1,7 +1,6 @@
 package asynqmon
 
 import (
-	"embed"
 	"errors"
 	"html/template"
 	"io/fs"
@@ -11,13 +10,11 @@ import (
 )
 
 // uiAssetsHandler is a http.Handler.
-// The path to the static file directory and
-// the path to the index file within that static directory are used to
-// serve the SPA.
+// The static file system and the path to the index file within that
+// file system are used to serve the SPA.
 type uiAssetsHandler struct {
 	rootPath       string
-	contents       embed.FS
-	staticDirPath  string
+	contents       fs.FS // static files rooted at the UI assets directory
 	indexFileName  string
 	prometheusAddr string
 	readOnly       bool
@@ -31,32 +28,28 @@ func (h *uiAssetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Get the absolute path to prevent directory traversal.
 	path, err := filepath.Abs(r.URL.Path)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 		return
 	}
 
 	// Get the path relative to the root path.
 	if !strings.HasPrefix(path, h.rootPath) {
-		http.Error(w, "unexpected path prefix", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "unexpected path prefix")
 		return
 	}
 	path = strings.TrimPrefix(path, h.rootPath)
 
 	if code, err := h.serveFile(w, path); err != nil {
-		http.Error(w, err.Error(), code)
+		respondError(w, code, errorCodeForStatus(code), err.Error())
 		return
 	}
 }
 
-func (h *uiAssetsHandler) indexFilePath() string {
-	return filepath.Join(h.staticDirPath, h.indexFileName)
-}
-
 func (h *uiAssetsHandler) renderIndexFile(w http.ResponseWriter) error {
 	// Note: Replace the default delimiter ("{{") with a custom one
 	// since webpack escapes the '{' character when it compiles the index.html file.
 	// See the "homepage" field in package.json.
-	tmpl, err := template.New(h.indexFileName).Delims("/[[", "]]").ParseFS(h.contents, h.indexFilePath())
+	tmpl, err := template.New(h.indexFileName).Delims("/[[", "]]").ParseFS(h.contents, h.indexFileName)
 	if err != nil {
 		return err
 	}
@@ -85,8 +78,9 @@ func (h *uiAssetsHandler) serveFile(w http.ResponseWriter, path string) (code in
 		}
 		return http.StatusOK, nil
 	}
-	path = filepath.Join(h.staticDirPath, path)
-	bytes, err := h.contents.ReadFile(path)
+	// Paths in fs.FS are unrooted and slash-separated.
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	bytes, err := fs.ReadFile(h.contents, path)
 	if err != nil {
 		// If path is error (e.g. file not exist, path is a directory), serve index file.
 		var pathErr *fs.PathError
@@ -0,0 +1,113 @@
+package asynqmon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - in-memory cache of queue stats refreshed in the background
+// ****************************************************************************
+
+// queueStatsCache holds snapshots of all queues, which are refreshed periodically
+// so that polling clients do not hit redis on every request.
+type queueStatsCache struct {
+	inspector *asynq.Inspector
+	interval  time.Duration
+
+	mu        sync.RWMutex
+	snapshots []*queueStateSnapshot
+	cachedAt  time.Time
+
+	done chan struct{}
+	once sync.Once
+}
+
+// newQueueStatsCache creates a cache and starts refreshing it every interval.
+func newQueueStatsCache(inspector *asynq.Inspector, interval time.Duration) *queueStatsCache {
+	c := &queueStatsCache{
+		inspector: inspector,
+		interval:  interval,
+		done:      make(chan struct{}),
+	}
+	go c.start()
+	return c
+}
+
+func (c *queueStatsCache) start() {
+	c.refresh()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.refresh()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// refresh fetches the current stats of all queues and stores them in the cache.
+// On error, the cache is left unchanged.
+func (c *queueStatsCache) refresh() ([]*queueStateSnapshot, time.Time, error) {
+	snapshots, err := fetchQueueStateSnapshots(traceInspector(context.Background(), c.inspector))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	now := time.Now()
+	c.mu.Lock()
+	c.snapshots, c.cachedAt = snapshots, now
+	c.mu.Unlock()
+	return snapshots, now, nil
+}
+
+// get returns the cached snapshots and the time they were cached at.
+// ok is false if the cache is empty or the snapshots are stale, in which case
+// the caller should fetch the stats from redis.
+func (c *queueStatsCache) get() (snapshots []*queueStateSnapshot, cachedAt time.Time, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	// Allow one missed refresh before treating the data as stale.
+	if c.snapshots == nil || time.Since(c.cachedAt) > 2*c.interval {
+		return nil, time.Time{}, false
+	}
+	return c.snapshots, c.cachedAt, true
+}
+
+// Close stops refreshing the cache.
+func (c *queueStatsCache) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+// fetchQueueStateSnapshots returns the current state of all queues.
+func fetchQueueStateSnapshots(inspector *tracedInspector) ([]*queueStateSnapshot, error) {
+	return fetchMatchingQueueStateSnapshots(inspector, "")
+}
+
+// fetchMatchingQueueStateSnapshots returns the current state of the queues whose name
+// matches the glob pattern. An empty pattern matches all queues.
+// The pattern must be valid (see validQueueNamePattern).
+func fetchMatchingQueueStateSnapshots(inspector *tracedInspector, pattern string) ([]*queueStateSnapshot, error) {
+	qnames, err := inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*queueStateSnapshot, 0, len(qnames))
+	for _, qname := range qnames {
+		if !matchQueueName(pattern, qname) {
+			continue
+		}
+		qinfo, err := inspector.GetQueueInfo(qname)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, toQueueStateSnapshot(qinfo))
+	}
+	return snapshots, nil
+}
@@ -1,14 +1,19 @@
 package asynqmon
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 
 	"github.com/hibiken/asynq"
@@ -20,30 +25,82 @@ import (
 // ****************************************************************************
 
 type listActiveTasksResponse struct {
-	Tasks []*activeTask       `json:"tasks"`
+	// []*activeTask, or the projected tasks if the fields param is set.
+	Tasks interface{}         `json:"tasks"`
 	Stats *queueStateSnapshot `json:"stats"`
+	// Names in the fields param which are not fields of active tasks.
+	IgnoredFields []string `json:"ignored_fields,omitempty"`
+	// Truncated indicates that the scan for orphaned tasks stopped at maxOrphanedTasksScan
+	// active tasks, so orphaned tasks beyond that point are not listed.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
-func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+// Maximum number of active tasks scanned to list orphaned tasks.
+// Active tasks are bounded by the concurrency of workers, so the limit is only reached
+// by very large deployments.
+const maxOrphanedTasksScan = 10000
+
+// newListActiveTasksHandlerFunc returns a handler which lists active tasks with their worker and lease info.
+// With ?orphaned=true, only tasks whose lease has expired are listed.
+// NDJSON responses do not include lease info, and cannot be filtered by the orphaned param.
+// With ?fields=, tasks are projected to the named fields (see projectTasks).
+func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, rc redis.UniversalClient, pf PayloadFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			if r.URL.Query().Get("orphaned") != "" {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "orphaned is not supported with NDJSON responses")
+				return
+			}
+			streamTasksNDJSON(w, r, inspector.ListActiveTasks, "active", func(t *asynq.TaskInfo) interface{} { return toActiveTask(t, pf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
+		var orphaned bool
+		if s := r.URL.Query().Get("orphaned"); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for orphaned: %q", s))
+				return
+			}
+			orphaned = b
+		}
 
-		tasks, err := inspector.ListActiveTasks(
-			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		var (
+			tasks     []*asynq.TaskInfo
+			truncated bool
+			err       error
+		)
+		if orphaned {
+			// Active tasks are bounded by the concurrency of workers, so scan all of them
+			// and paginate the filtered list.
+			_, truncated, err = scanTasks(inspector.ListActiveTasks, qname, maxOrphanedTasksScan, func(t *asynq.TaskInfo) error {
+				tasks = append(tasks, t)
+				return nil
+			})
+		} else {
+			tasks, err = inspector.ListActiveTasks(
+				qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		servers, err := inspector.Servers()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
+			return
+		}
+		leases, err := getLeaseExpirations(r.Context(), rc, qname, tasks)
+		if err != nil {
+			writeInternalServerError(w, r, err)
 			return
 		}
 		// m maps taskID to workerInfo.
@@ -55,8 +112,9 @@ func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatt
 				}
 			}
 		}
-		activeTasks := toActiveTasks(tasks, pf)
-		for _, t := range activeTasks {
+		now := time.Now()
+		activeTasks := make([]*activeTask, 0, len(tasks))
+		for _, t := range toActiveTasks(tasks, pf) {
 			workerInfo, ok := m[t.ID]
 			if ok {
 				t.Started = workerInfo.Started.Format(time.RFC3339)
@@ -65,21 +123,88 @@ func newListActiveTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatt
 				t.Started = "-"
 				t.Deadline = "-"
 			}
+			if lease, ok := leases[t.ID]; ok {
+				t.LeaseExpiration = lease.Format(time.RFC3339)
+				t.LeaseExpired = lease.Before(now)
+			} else {
+				t.LeaseExpiration = "-"
+			}
+			if orphaned && !t.LeaseExpired {
+				continue
+			}
+			activeTasks = append(activeTasks, t)
+		}
+		if orphaned {
+			activeTasks = paginateActiveTasks(activeTasks, pageSize, pageNum)
+		}
+		annotations.attachTasks(r, qname, activeTasks)
+		projected, ignored, err := projectTasks(r, activeTasks, "active")
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
 		}
 
 		resp := listActiveTasksResponse{
-			Tasks: activeTasks,
-			Stats: toQueueStateSnapshot(qinfo),
+			Tasks:         projected,
+			Stats:         toQueueStateSnapshot(qinfo),
+			IgnoredFields: ignored,
+			Truncated:     truncated,
 		}
-		writeResponseJSON(w, resp)
+		writeResponseJSONWithETag(w, r, resp, resp.Tasks, snapshotForETag(resp.Stats))
 	}
 }
 
+// getLeaseExpirations returns the lease expiration time of the given active tasks keyed by task ID.
+// Tasks without a lease are not included.
+func getLeaseExpirations(ctx context.Context, rc redis.UniversalClient, qname string, tasks []*asynq.TaskInfo) (map[string]time.Time, error) {
+	res := make(map[string]time.Time)
+	if len(tasks) == 0 {
+		return res, nil
+	}
+	key := queueKeyPrefix(qname) + "lease"
+	cmds := make([]*redis.FloatCmd, len(tasks))
+	pipe := rc.Pipeline()
+	for i, t := range tasks {
+		cmds[i] = pipe.ZScore(ctx, key, t.ID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		score, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res[tasks[i].ID] = time.Unix(int64(score), 0)
+	}
+	return res, nil
+}
+
+// paginateActiveTasks returns the tasks in the page specified by pageSize and pageNum.
+func paginateActiveTasks(tasks []*activeTask, pageSize, pageNum int) []*activeTask {
+	if pageSize <= 0 || pageNum <= 0 {
+		return tasks[:0]
+	}
+	start := (pageNum - 1) * pageSize
+	if start >= len(tasks) {
+		return tasks[:0]
+	}
+	end := start + pageSize
+	if end > len(tasks) {
+		end = len(tasks)
+	}
+	return tasks[start:end]
+}
+
 func newCancelActiveTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		id := mux.Vars(r)["task_id"]
 		if err := inspector.CancelProcessing(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -88,18 +213,19 @@ func newCancelActiveTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc
 
 func newCancelAllActiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		const batchSize = 100
 		page := 1
 		qname := mux.Vars(r)["qname"]
 		for {
 			tasks, err := inspector.ListActiveTasks(qname, asynq.Page(page), asynq.PageSize(batchSize))
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeInternalServerError(w, r, err)
 				return
 			}
 			for _, t := range tasks {
 				if err := inspector.CancelProcessing(t.ID); err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
+					writeInternalServerError(w, r, err)
 					return
 				}
 			}
@@ -123,13 +249,14 @@ type batchCancelTasksResponse struct {
 
 func newBatchCancelActiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 
 		var req batchCancelTasksRequest
 		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 
@@ -140,7 +267,7 @@ func newBatchCancelActiveTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 		}
 		for _, id := range req.TaskIDs {
 			if err := inspector.CancelProcessing(id); err != nil {
-				log.Printf("error: could not send cancelation signal to task %s", id)
+				logRequestf(r, "error: could not send cancelation signal to task %s", id)
 				resp.ErrorIDs = append(resp.ErrorIDs, id)
 			} else {
 				resp.CanceledIDs = append(resp.CanceledIDs, id)
@@ -150,20 +277,25 @@ func newBatchCancelActiveTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 	}
 }
 
-func newListPendingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListPendingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			streamTasksNDJSON(w, r, inspector.ListPendingTasks, "pending", func(t *asynq.TaskInfo) interface{} { return toPendingTask(t, pf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
 		tasks, err := inspector.ListPendingTasks(
 			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -171,27 +303,43 @@ func newListPendingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormat
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*pendingTask, 0)
 		} else {
-			payload["tasks"] = toPendingTasks(tasks, pf)
+			converted := toPendingTasks(tasks, pf)
+			annotations.attachTasks(r, qname, converted)
+			payload["tasks"] = converted
 		}
-		payload["stats"] = toQueueStateSnapshot(qinfo)
-		writeResponseJSON(w, payload)
+		if err := applyFieldProjection(r, payload, "pending"); err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		stats := toQueueStateSnapshot(qinfo)
+		payload["stats"] = stats
+		writeResponseJSONWithETag(w, r, payload, payload["tasks"], snapshotForETag(stats))
 	}
 }
 
-func newListScheduledTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListScheduledTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			streamTasksNDJSON(w, r, inspector.ListScheduledTasks, "scheduled", func(t *asynq.TaskInfo) interface{} { return toScheduledTask(t, pf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
 		tasks, err := inspector.ListScheduledTasks(
 			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if err := sortTasks(r, tasks); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -199,27 +347,43 @@ func newListScheduledTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadForm
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*scheduledTask, 0)
 		} else {
-			payload["tasks"] = toScheduledTasks(tasks, pf)
+			converted := toScheduledTasks(tasks, pf)
+			annotations.attachTasks(r, qname, converted)
+			payload["tasks"] = converted
 		}
-		payload["stats"] = toQueueStateSnapshot(qinfo)
-		writeResponseJSON(w, payload)
+		if err := applyFieldProjection(r, payload, "scheduled"); err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		stats := toQueueStateSnapshot(qinfo)
+		payload["stats"] = stats
+		writeResponseJSONWithETag(w, r, payload, payload["tasks"], snapshotForETag(stats))
 	}
 }
 
-func newListRetryTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListRetryTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			streamTasksNDJSON(w, r, inspector.ListRetryTasks, "retry", func(t *asynq.TaskInfo) interface{} { return toRetryTask(t, pf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
 		tasks, err := inspector.ListRetryTasks(
 			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if err := sortTasks(r, tasks); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -227,27 +391,43 @@ func newListRetryTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatte
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*retryTask, 0)
 		} else {
-			payload["tasks"] = toRetryTasks(tasks, pf)
+			converted := toRetryTasks(tasks, pf)
+			annotations.attachTasks(r, qname, converted)
+			payload["tasks"] = converted
+		}
+		if err := applyFieldProjection(r, payload, "retry"); err != nil {
+			writeInternalServerError(w, r, err)
+			return
 		}
-		payload["stats"] = toQueueStateSnapshot(qinfo)
-		writeResponseJSON(w, payload)
+		stats := toQueueStateSnapshot(qinfo)
+		payload["stats"] = stats
+		writeResponseJSONWithETag(w, r, payload, payload["tasks"], snapshotForETag(stats))
 	}
 }
 
-func newListArchivedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListArchivedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			streamTasksNDJSON(w, r, inspector.ListArchivedTasks, "archived", func(t *asynq.TaskInfo) interface{} { return toArchivedTask(t, pf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
 		tasks, err := inspector.ListArchivedTasks(
 			qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if err := sortTasks(r, tasks); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -255,26 +435,38 @@ func newListArchivedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadForma
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*archivedTask, 0)
 		} else {
-			payload["tasks"] = toArchivedTasks(tasks, pf)
+			converted := toArchivedTasks(tasks, pf)
+			annotations.attachTasks(r, qname, converted)
+			payload["tasks"] = converted
+		}
+		if err := applyFieldProjection(r, payload, "archived"); err != nil {
+			writeInternalServerError(w, r, err)
+			return
 		}
-		payload["stats"] = toQueueStateSnapshot(qinfo)
-		writeResponseJSON(w, payload)
+		stats := toQueueStateSnapshot(qinfo)
+		payload["stats"] = stats
+		writeResponseJSONWithETag(w, r, payload, payload["tasks"], snapshotForETag(stats))
 	}
 }
 
-func newListCompletedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, rf ResultFormatter) http.HandlerFunc {
+func newListCompletedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, rf ResultFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			streamTasksNDJSON(w, r, inspector.ListCompletedTasks, "completed", func(t *asynq.TaskInfo) interface{} { return toCompletedTask(t, pf, rf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
 		tasks, err := inspector.ListCompletedTasks(qname, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -282,33 +474,48 @@ func newListCompletedTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadForm
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*completedTask, 0)
 		} else {
-			payload["tasks"] = toCompletedTasks(tasks, pf, rf)
+			converted := toCompletedTasks(tasks, pf, rf)
+			annotations.attachTasks(r, qname, converted)
+			payload["tasks"] = converted
+		}
+		if err := applyFieldProjection(r, payload, "completed"); err != nil {
+			writeInternalServerError(w, r, err)
+			return
 		}
-		payload["stats"] = toQueueStateSnapshot(qinfo)
-		writeResponseJSON(w, payload)
+		stats := toQueueStateSnapshot(qinfo)
+		payload["stats"] = stats
+		writeResponseJSONWithETag(w, r, payload, payload["tasks"], snapshotForETag(stats))
 	}
 }
 
-func newListAggregatingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter) http.HandlerFunc {
+func newListAggregatingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, pageSizes map[string]PageSizes, annotations *annotationStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname := vars["qname"]
 		gname := vars["gname"]
-		pageSize, pageNum := getPageOptions(r)
+		if acceptsNDJSON(r) {
+			list := func(qname string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+				return inspector.ListAggregatingTasks(qname, gname, opts...)
+			}
+			streamTasksNDJSON(w, r, list, "aggregating", func(t *asynq.TaskInfo) interface{} { return toAggregatingTask(t, pf) })
+			return
+		}
+		pageSize, pageNum := getQueuePageOptions(r, pageSizes)
 		tasks, err := inspector.ListAggregatingTasks(
 			qname, gname, asynq.PageSize(pageSize), asynq.Page(pageNum))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		qinfo, err := inspector.GetQueueInfo(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		groups, err := inspector.Groups(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		payload := make(map[string]interface{})
@@ -316,25 +523,33 @@ func newListAggregatingTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFo
 			// avoid nil for the tasks field in json output.
 			payload["tasks"] = make([]*aggregatingTask, 0)
 		} else {
-			payload["tasks"] = toAggregatingTasks(tasks, pf)
+			converted := toAggregatingTasks(tasks, pf)
+			annotations.attachTasks(r, qname, converted)
+			payload["tasks"] = converted
 		}
-		payload["stats"] = toQueueStateSnapshot(qinfo)
+		if err := applyFieldProjection(r, payload, "aggregating"); err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		stats := toQueueStateSnapshot(qinfo)
+		payload["stats"] = stats
 		payload["groups"] = toGroupInfos(groups)
-		writeResponseJSON(w, payload)
+		writeResponseJSONWithETag(w, r, payload, payload["tasks"], payload["groups"], snapshotForETag(stats))
 	}
 }
 
 func newDeleteTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" || taskid == "" {
-			http.Error(w, "route parameters should not be empty", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
 			return
 		}
 		if err := inspector.DeleteTask(qname, taskid); err != nil {
 			// TODO: Handle task not found error and return 404
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -343,15 +558,16 @@ func newDeleteTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 
 func newRunTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" || taskid == "" {
-			http.Error(w, "route parameters should not be empty", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
 			return
 		}
 		if err := inspector.RunTask(qname, taskid); err != nil {
 			// TODO: Handle task not found error and return 404
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -360,15 +576,16 @@ func newRunTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 
 func newArchiveTaskHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" || taskid == "" {
-			http.Error(w, "route parameters should not be empty", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
 			return
 		}
 		if err := inspector.ArchiveTask(qname, taskid); err != nil {
 			// TODO: Handle task not found error and return 404
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -382,10 +599,11 @@ type deleteAllTasksResponse struct {
 
 func newDeleteAllPendingTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllPendingTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -394,11 +612,12 @@ func newDeleteAllPendingTasksHandlerFunc(inspector *asynq.Inspector) http.Handle
 
 func newDeleteAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, gname := vars["qname"], vars["gname"]
 		n, err := inspector.DeleteAllAggregatingTasks(qname, gname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -407,10 +626,11 @@ func newDeleteAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.Ha
 
 func newDeleteAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllScheduledTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -419,10 +639,11 @@ func newDeleteAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.Hand
 
 func newDeleteAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllRetryTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -431,10 +652,11 @@ func newDeleteAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerF
 
 func newDeleteAllArchivedTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllArchivedTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -443,10 +665,11 @@ func newDeleteAllArchivedTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 
 func newDeleteAllCompletedTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.DeleteAllCompletedTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, deleteAllTasksResponse{n})
@@ -460,10 +683,11 @@ type runAllTasksResponse struct {
 
 func newRunAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.RunAllScheduledTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -472,10 +696,11 @@ func newRunAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.Handler
 
 func newRunAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.RunAllRetryTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -484,23 +709,98 @@ func newRunAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc
 
 func newRunAllArchivedTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.RunAllArchivedTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
 	}
 }
 
+// Maximum number of archived tasks run by a single throttled run job.
+const maxThrottledRunScan = 100000
+
+// Maximum rate of a throttled run in tasks per second.
+const maxThrottledRunRate = 1000
+
+type runAllTasksThrottledRequest struct {
+	// Number of tasks to run per second.
+	Rate float64 `json:"rate"`
+}
+
+// newRunAllArchivedTasksThrottledHandlerFunc returns a handler which starts a job to run
+// all archived tasks in the queue at the given rate, and responds with the job.
+// The progress of the job can be checked, and the job can be canceled, via the job endpoints.
+// At most maxThrottledRunScan tasks are run by a job; the job is reported as truncated
+// if the queue has more archived tasks.
+func newRunAllArchivedTasksThrottledHandlerFunc(inspector *asynq.Inspector, jobs *jobRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req runAllTasksThrottledRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if req.Rate <= 0 || req.Rate > maxThrottledRunRate {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("rate must be greater than 0 and at most %d", maxThrottledRunRate))
+			return
+		}
+
+		qname := mux.Vars(r)["qname"]
+		// The job outlives the request, so only the queue lookup is traced.
+		qnames, err := traceInspector(r.Context(), inspector).Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !contains(qnames, qname) {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", qname))
+			return
+		}
+
+		interval := time.Duration(float64(time.Second) / req.Rate)
+		j := jobs.start(qname, func(j *job) error {
+			var ids []string
+			_, truncated, err := scanTasks(inspector.ListArchivedTasks, qname, maxThrottledRunScan, func(t *asynq.TaskInfo) error {
+				ids = append(ids, t.ID)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			j.setTotal(len(ids), truncated)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for _, id := range ids {
+				select {
+				case <-j.done():
+					return nil
+				case <-ticker.C:
+				}
+				// A task may have been run or deleted since the scan, which is counted as a failure.
+				j.record(inspector.RunTask(qname, id))
+			}
+			return nil
+		})
+		w.WriteHeader(http.StatusAccepted)
+		writeResponseJSON(w, j.info())
+	}
+}
+
 func newRunAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, gname := vars["qname"], vars["gname"]
 		n, err := inspector.RunAllAggregatingTasks(qname, gname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, runAllTasksResponse{n})
@@ -514,16 +814,17 @@ type archiveAllTasksResponse struct {
 
 func writeResponseJSON(w http.ResponseWriter, resp interface{}) {
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
 	}
 }
 
 func newArchiveAllPendingTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.ArchiveAllPendingTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -532,11 +833,12 @@ func newArchiveAllPendingTasksHandlerFunc(inspector *asynq.Inspector) http.Handl
 
 func newArchiveAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, gname := vars["qname"], vars["gname"]
 		n, err := inspector.ArchiveAllAggregatingTasks(qname, gname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -545,10 +847,11 @@ func newArchiveAllAggregatingTasksHandlerFunc(inspector *asynq.Inspector) http.H
 
 func newArchiveAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.ArchiveAllScheduledTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -557,10 +860,11 @@ func newArchiveAllScheduledTasksHandlerFunc(inspector *asynq.Inspector) http.Han
 
 func newArchiveAllRetryTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		qname := mux.Vars(r)["qname"]
 		n, err := inspector.ArchiveAllRetryTasks(qname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
 		writeResponseJSON(w, archiveAllTasksResponse{n})
@@ -590,13 +894,14 @@ const maxRequestBodySize = 1000000
 
 func newBatchDeleteTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 
 		var req batchDeleteTasksRequest
 		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 
@@ -608,7 +913,7 @@ func newBatchDeleteTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc
 		}
 		for _, taskid := range req.TaskIDs {
 			if err := inspector.DeleteTask(qname, taskid); err != nil {
-				log.Printf("error: could not delete task with id %q: %v", taskid, err)
+				logRequestf(r, "error: could not delete task with id %q: %v", taskid, err)
 				resp.FailedIDs = append(resp.FailedIDs, taskid)
 			} else {
 				resp.DeletedIDs = append(resp.DeletedIDs, taskid)
@@ -631,13 +936,14 @@ type batchRunTasksResponse struct {
 
 func newBatchRunTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 
 		var req batchRunTasksRequest
 		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 
@@ -649,7 +955,7 @@ func newBatchRunTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 		}
 		for _, taskid := range req.TaskIDs {
 			if err := inspector.RunTask(qname, taskid); err != nil {
-				log.Printf("error: could not run task with id %q: %v", taskid, err)
+				logRequestf(r, "error: could not run task with id %q: %v", taskid, err)
 				resp.ErrorIDs = append(resp.ErrorIDs, taskid)
 			} else {
 				resp.PendingIDs = append(resp.PendingIDs, taskid)
@@ -672,13 +978,14 @@ type batchArchiveTasksResponse struct {
 
 func newBatchArchiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 
 		var req batchArchiveTasksRequest
 		if err := dec.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
 			return
 		}
 
@@ -690,7 +997,7 @@ func newBatchArchiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFun
 		}
 		for _, taskid := range req.TaskIDs {
 			if err := inspector.ArchiveTask(qname, taskid); err != nil {
-				log.Printf("error: could not archive task with id %q: %v", taskid, err)
+				logRequestf(r, "error: could not archive task with id %q: %v", taskid, err)
 				resp.ErrorIDs = append(resp.ErrorIDs, taskid)
 			} else {
 				resp.ArchivedIDs = append(resp.ArchivedIDs, taskid)
@@ -700,11 +1007,662 @@ func newBatchArchiveTasksHandlerFunc(inspector *asynq.Inspector) http.HandlerFun
 	}
 }
 
+type rescheduleTaskRequest struct {
+	// New time to process the task in RFC3339 format.
+	ProcessAt string `json:"process_at"`
+}
+
+type rescheduleTaskResponse struct {
+	// ID of the newly scheduled task.
+	ID string `json:"id"`
+	// Time the task is scheduled to be processed.
+	NextProcessAt time.Time `json:"next_process_at"`
+}
+
+// newRescheduleTaskHandlerFunc returns a handler which moves a scheduled task to a new process time.
+// The task is re-enqueued with the same type, payload and options, and the original task is deleted.
+func newRescheduleTaskHandlerFunc(inspector *asynq.Inspector, client *asynq.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if qname == "" || taskid == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req rescheduleTaskRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		processAt, err := time.Parse(time.RFC3339, req.ProcessAt)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for process_at: %q", req.ProcessAt))
+			return
+		}
+		if !processAt.After(time.Now()) {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "process_at must be in the future")
+			return
+		}
+
+		info, err := inspector.GetTaskInfo(qname, taskid)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if info.State != asynq.TaskStateScheduled {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("task is in %s state, only scheduled tasks can be rescheduled", info.State))
+			return
+		}
+
+		newInfo, err := reenqueueTask(r, inspector, client, info, processAt, asynq.ProcessAt(processAt))
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, rescheduleTaskResponse{
+			ID:            newInfo.ID,
+			NextProcessAt: newInfo.NextProcessAt,
+		})
+	}
+}
+
+type runTaskWithRetriesRequest struct {
+	// Maximum number of retries of the new task.
+	MaxRetry *int `json:"max_retry"`
+}
+
+type runTaskWithRetriesResponse struct {
+	// ID of the newly enqueued task.
+	ID string `json:"id"`
+	// Maximum number of retries of the newly enqueued task.
+	MaxRetry int `json:"max_retry"`
+}
+
+// newRunArchivedTaskWithRetriesHandlerFunc returns a handler which runs an archived task with a new retry budget.
+// The task is re-enqueued with the same type, payload and options except for max retry, and the original task is deleted.
+// The deadline of the task is dropped if it has passed, so that the new retry budget is not wasted.
+func newRunArchivedTaskWithRetriesHandlerFunc(inspector *asynq.Inspector, client *asynq.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if qname == "" || taskid == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req runTaskWithRetriesRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if req.MaxRetry == nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "max_retry is required")
+			return
+		}
+		if *req.MaxRetry < 0 {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for max_retry: %d", *req.MaxRetry))
+			return
+		}
+
+		info, err := inspector.GetTaskInfo(qname, taskid)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if info.State != asynq.TaskStateArchived {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("task is in %s state, only archived tasks can be run with retries", info.State))
+			return
+		}
+
+		newInfo, err := reenqueueTask(r, inspector, client, info, time.Now(), asynq.MaxRetry(*req.MaxRetry))
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, runTaskWithRetriesResponse{
+			ID:       newInfo.ID,
+			MaxRetry: newInfo.MaxRetry,
+		})
+	}
+}
+
+type moveTaskRequest struct {
+	// Name of the queue to move the task to.
+	Queue string `json:"queue"`
+}
+
+type moveTaskResponse struct {
+	// ID of the task in the target queue.
+	ID string `json:"id"`
+	// Name of the queue the task was moved to.
+	Queue string `json:"queue"`
+}
+
+// movableTaskStates maps the state in the route to the task state.
+// Active tasks cannot be moved since they are being processed by a worker.
+var movableTaskStates = map[string]asynq.TaskState{
+	"pending":   asynq.TaskStatePending,
+	"scheduled": asynq.TaskStateScheduled,
+	"retry":     asynq.TaskStateRetry,
+	"archived":  asynq.TaskStateArchived,
+	"completed": asynq.TaskStateCompleted,
+}
+
+// newMoveTaskHandlerFunc returns a handler which moves a task to another queue.
+// The task is enqueued to the target queue with the same type, payload and options, and the original task is deleted.
+// Scheduled tasks keep their process time; tasks in other states become pending in the target queue.
+func newMoveTaskHandlerFunc(inspector *asynq.Inspector, client *asynq.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if qname == "" || taskid == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
+			return
+		}
+		state, ok := movableTaskStates[vars["state"]]
+		if !ok {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("cannot move tasks in %s state", vars["state"]))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req moveTaskRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if req.Queue == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "queue is required")
+			return
+		}
+		if req.Queue == qname {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "target queue must be different from the source queue")
+			return
+		}
+		qnames, err := inspector.Queues()
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if !contains(qnames, req.Queue) {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("queue %q not found", req.Queue))
+			return
+		}
+
+		info, err := inspector.GetTaskInfo(qname, taskid)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+		if info.State != state {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("task is in %s state, not %s", info.State, state))
+			return
+		}
+
+		opts := []asynq.Option{asynq.Queue(req.Queue)}
+		processAt := time.Now()
+		if info.State == asynq.TaskStateScheduled {
+			processAt = info.NextProcessAt
+			opts = append(opts, asynq.ProcessAt(processAt))
+		}
+		newInfo, err := reenqueueTask(r, inspector, client, info, processAt, opts...)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		writeResponseJSON(w, moveTaskResponse{
+			ID:    newInfo.ID,
+			Queue: newInfo.Queue,
+		})
+	}
+}
+
+// reenqueueTask enqueues a copy of the task described by info, with opts applied on top of
+// taskOptions(info, processAt), and deletes the original task.
+// The copy is enqueued first so that the task is not lost on failure; if the original task
+// cannot be deleted, the copy is deleted to avoid processing the task twice.
+func reenqueueTask(r *http.Request, inspector *tracedInspector, client *asynq.Client, info *asynq.TaskInfo, processAt time.Time, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	opts = append(taskOptions(info, processAt), opts...)
+	newInfo, err := client.Enqueue(asynq.NewTask(info.Type, info.Payload), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := inspector.DeleteTask(info.Queue, info.ID); err != nil {
+		if err := inspector.DeleteTask(newInfo.Queue, newInfo.ID); err != nil {
+			logRequestf(r, "error: could not delete re-enqueued task with id %q: %v", newInfo.ID, err)
+		}
+		return nil, err
+	}
+	return newInfo, nil
+}
+
+// taskOptions returns the options to re-enqueue a task described by the given info
+// into the same queue with the same retry budget, timeout, deadline and retention.
+// The deadline is dropped if it is not after processAt, since the task would fail as soon as it is processed.
+func taskOptions(info *asynq.TaskInfo, processAt time.Time) []asynq.Option {
+	opts := []asynq.Option{
+		asynq.Queue(info.Queue),
+		asynq.MaxRetry(info.MaxRetry),
+	}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if info.Deadline.After(processAt) {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	if info.Retention > 0 {
+		opts = append(opts, asynq.Retention(info.Retention))
+	}
+	return opts
+}
+
+// listTasksFunc lists tasks of a given state in a queue (e.g. Inspector.ListRetryTasks).
+type listTasksFunc func(qname string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+
+// Maximum number of tasks scanned by a single "by type" batch request.
+const maxTasksByTypeScan = 10000
+
+type runTasksByTypeRequest struct {
+	Type string `json:"type"`
+}
+
+type runTasksByTypeResponse struct {
+	// Number of tasks with the given type found in the scan.
+	Matched int `json:"matched"`
+	// Number of tasks successfully moved to the pending state.
+	// Always zero in dry-run mode.
+	Scheduled int `json:"scheduled"`
+	// Number of tasks that were not able to move to the pending state.
+	Failed int `json:"failed"`
+	// DryRun indicates that no tasks were run.
+	DryRun bool `json:"dry_run"`
+	// Truncated indicates that the scan stopped before reaching the end of the queue.
+	Truncated bool `json:"truncated"`
+}
+
+// newRunTasksByTypeHandlerFunc returns a handler which runs every task of the
+// type given in the request body from the tasks in the given state (see listTasksForState).
+//
+// Optional query params:
+// `dry_run`: if true, only reports the number of matching tasks without running them
+func newRunTasksByTypeHandlerFunc(inspector *asynq.Inspector, state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		list, _ := listTasksForState(inspector, state)
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req runTasksByTypeRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if req.Type == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "task type cannot be empty")
+			return
+		}
+		var dryRun bool
+		if s := r.URL.Query().Get("dry_run"); s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for dry_run: %q", s))
+				return
+			}
+			dryRun = v
+		}
+
+		qname := mux.Vars(r)["qname"]
+		ids, truncated, err := findTaskIDsByType(list, qname, req.Type)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		resp := runTasksByTypeResponse{
+			Matched:   len(ids),
+			DryRun:    dryRun,
+			Truncated: truncated,
+		}
+		if !dryRun {
+			for _, id := range ids {
+				if err := inspector.RunTask(qname, id); err != nil {
+					logRequestf(r, "error: could not run task with id %q: %v", id, err)
+					resp.Failed++
+				} else {
+					resp.Scheduled++
+				}
+			}
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+// findTaskIDsByType returns the IDs of tasks with the given type.
+// At most maxTasksByTypeScan tasks are scanned, and truncated reports whether
+// more tasks were left unscanned.
+//
+// IDs are collected before acting on any of them so that moving tasks out of
+// the listed state does not shift the pages being scanned.
+func findTaskIDsByType(list listTasksFunc, qname, taskType string) (ids []string, truncated bool, err error) {
+	_, truncated, err = scanTasks(list, qname, maxTasksByTypeScan, func(t *asynq.TaskInfo) error {
+		if t.Type == taskType {
+			ids = append(ids, t.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return ids, truncated, nil
+}
+
+// errStopScan is returned by a scanTasks callback to stop the scan early.
+var errStopScan = errors.New("stop scan")
+
+// scanTasks pages through the tasks returned by list and calls fn for each task.
+// It stops after scanning limit tasks, and truncated reports whether more tasks
+// were left unscanned.
+// If fn returns errStopScan, the scan stops after the task and is reported as truncated
+// unless the task is known to be the last one; any other error stops the scan and is returned.
+func scanTasks(list listTasksFunc, qname string, limit int, fn func(*asynq.TaskInfo) error) (scanned int, truncated bool, err error) {
+	const batchSize = 100
+	for page := 1; ; page++ {
+		tasks, err := list(qname, asynq.Page(page), asynq.PageSize(batchSize))
+		if err != nil {
+			return 0, false, err
+		}
+		for i, t := range tasks {
+			if scanned == limit {
+				// The scan is truncated only if there is a task past the limit.
+				return scanned, true, nil
+			}
+			switch err := fn(t); {
+			case errors.Is(err, errStopScan):
+				// A full page may be followed by more tasks.
+				return scanned + 1, i < len(tasks)-1 || len(tasks) == batchSize, nil
+			case err != nil:
+				return 0, false, err
+			}
+			scanned++
+		}
+		if len(tasks) < batchSize {
+			return scanned, false, nil
+		}
+	}
+}
+
+// Maximum number of tasks scanned to build an error summary.
+const maxErrorSummaryScan = 10000
+
+// Default and maximum number of distinct errors returned in an error summary.
+const (
+	defaultErrorSummaryLimit = 10
+	maxErrorSummaryLimit     = 100
+)
+
+type errorCount struct {
+	// Normalized error message.
+	Message string `json:"error_message"`
+	// Number of tasks failed with the error.
+	Count int `json:"count"`
+}
+
+type errorSummaryResponse struct {
+	// Most frequent errors, sorted by count in descending order.
+	Errors []*errorCount `json:"errors"`
+	// Number of distinct errors found in the scan.
+	Distinct int `json:"distinct"`
+	// Number of tasks scanned.
+	Scanned int `json:"scanned"`
+	// Truncated indicates that the scan stopped before reaching the end of the queue.
+	Truncated bool `json:"truncated"`
+}
+
+// newErrorSummaryHandlerFunc returns a handler which groups the tasks in the given state
+// (see listTasksForState) by their last error and returns the most frequent errors.
+//
+// Optional query params:
+// `limit`: maximum number of distinct errors to return (default 10, max 100)
+func newErrorSummaryHandlerFunc(inspector *asynq.Inspector, state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, _ := listTasksForState(traceInspector(r.Context(), inspector), state)
+		limit := defaultErrorSummaryLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for limit: %q", s))
+				return
+			}
+			limit = n
+		}
+		if limit > maxErrorSummaryLimit {
+			limit = maxErrorSummaryLimit
+		}
+
+		qname := mux.Vars(r)["qname"]
+		counts := make(map[string]int)
+		scanned, truncated, err := scanTasks(list, qname, maxErrorSummaryScan, func(t *asynq.TaskInfo) error {
+			counts[normalizeErrorMessage(t.LastErr)]++
+			return nil
+		})
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		errs := make([]*errorCount, 0, len(counts))
+		for msg, n := range counts {
+			errs = append(errs, &errorCount{Message: msg, Count: n})
+		}
+		sort.Slice(errs, func(i, j int) bool {
+			if errs[i].Count != errs[j].Count {
+				return errs[i].Count > errs[j].Count
+			}
+			return errs[i].Message < errs[j].Message
+		})
+		resp := errorSummaryResponse{
+			Distinct:  len(errs),
+			Scanned:   scanned,
+			Truncated: truncated,
+		}
+		if len(errs) > limit {
+			errs = errs[:limit]
+		}
+		resp.Errors = errs
+		writeResponseJSON(w, resp)
+	}
+}
+
+// normalizeErrorMessage collapses whitespace in msg so that
+// near-identical error messages are grouped together.
+func normalizeErrorMessage(msg string) string {
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+// Default and maximum number of tasks scanned to list task types of a queue.
+const (
+	defaultTaskTypesScan = 10000
+	maxTaskTypesScan     = 100000
+)
+
+// States scanned to list task types, in scan order.
+// Aggregating tasks are not included since they are listed per group.
+var taskTypesScanStates = []string{"active", "pending", "scheduled", "retry", "archived", "completed"}
+
+type taskTypeCount struct {
+	Type string `json:"type"`
+	// Number of scanned tasks of the type.
+	Count int `json:"count"`
+}
+
+type taskTypesResponse struct {
+	// Distinct task types found in the scan, sorted by type.
+	TaskTypes []*taskTypeCount `json:"task_types"`
+	// Number of tasks scanned.
+	Scanned int `json:"scanned"`
+	// Truncated indicates that the scan stopped before reaching the end of the queue,
+	// in which case some task types may be missing and counts are lower bounds.
+	Truncated bool `json:"truncated"`
+}
+
+// newListTaskTypesHandlerFunc returns a handler which returns the distinct task types
+// in a queue with the number of tasks of each type.
+// States are scanned in the order of taskTypesScanStates until the scan limit is reached.
+//
+// Optional query params:
+// `limit`: maximum number of tasks to scan (default 10000, max 100000)
+func newListTaskTypesHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		limit := defaultTaskTypesScan
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for limit: %q", s))
+				return
+			}
+			limit = n
+		}
+		if limit > maxTaskTypesScan {
+			limit = maxTaskTypesScan
+		}
+
+		qname := mux.Vars(r)["qname"]
+		counts := make(map[string]int)
+		var resp taskTypesResponse
+		for _, state := range taskTypesScanStates {
+			if resp.Scanned >= limit {
+				resp.Truncated = true
+				break
+			}
+			list, _ := listTasksForState(inspector, state)
+			scanned, truncated, err := scanTasks(list, qname, limit-resp.Scanned, func(t *asynq.TaskInfo) error {
+				counts[t.Type]++
+				return nil
+			})
+			switch {
+			case errors.Is(err, asynq.ErrQueueNotFound):
+				respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+				return
+			case err != nil:
+				writeInternalServerError(w, r, err)
+				return
+			}
+			resp.Scanned += scanned
+			if truncated {
+				resp.Truncated = true
+				break
+			}
+		}
+		resp.TaskTypes = make([]*taskTypeCount, 0, len(counts))
+		for typ, n := range counts {
+			resp.TaskTypes = append(resp.TaskTypes, &taskTypeCount{Type: typ, Count: n})
+		}
+		sort.Slice(resp.TaskTypes, func(i, j int) bool {
+			return resp.TaskTypes[i].Type < resp.TaskTypes[j].Type
+		})
+		writeResponseJSON(w, resp)
+	}
+}
+
+// Fields which can be specified with the `sort` query param in task list endpoints.
+var taskSortFields = map[string]func(a, b *asynq.TaskInfo) bool{
+	"retry_count":     func(a, b *asynq.TaskInfo) bool { return a.Retried < b.Retried },
+	"last_failed_at":  func(a, b *asynq.TaskInfo) bool { return a.LastFailedAt.Before(b.LastFailedAt) },
+	"next_process_at": func(a, b *asynq.TaskInfo) bool { return a.NextProcessAt.Before(b.NextProcessAt) },
+}
+
+// sortTasks sorts tasks by the field specified by the `sort` query param,
+// in the order specified by the `order` query param ("asc" or "desc", default "asc").
+// If `sort` is not set, tasks are left in their natural order.
+//
+// Note that only the tasks in the requested page are sorted, since
+// pagination is done by redis in the natural order of each state.
+func sortTasks(r *http.Request, tasks []*asynq.TaskInfo) error {
+	q := r.URL.Query()
+	field := q.Get("sort")
+	if field == "" {
+		return nil
+	}
+	less, ok := taskSortFields[field]
+	if !ok {
+		return fmt.Errorf("invalid value provided for sort: %q", field)
+	}
+	var desc bool
+	switch order := q.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return fmt.Errorf("invalid value provided for order: %q", order)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if desc {
+			return less(tasks[j], tasks[i])
+		}
+		return less(tasks[i], tasks[j])
+	})
+	return nil
+}
+
+// Default number of items in a page of list endpoints.
+const defaultPageSize = 20
+
+// PageSizes specifies the page sizes of task list endpoints.
+type PageSizes struct {
+	// Default is the page size used when the request does not specify one.
+	// If zero, the default page size of 20 is used.
+	Default int
+
+	// Max is the maximum page size a request can specify; larger sizes are reduced to Max.
+	// If zero, the page size is not limited.
+	Max int
+}
+
 // getPageOptions read page size and number from the request url if set,
 // otherwise it returns the default value.
 func getPageOptions(r *http.Request) (pageSize, pageNum int) {
-	pageSize = 20 // default page size
-	pageNum = 1   // default page num
+	return getPageOptionsWithSizes(r, PageSizes{})
+}
+
+// getQueuePageOptions is like getPageOptions, but uses the page sizes configured
+// for the queue in the request url, if any.
+func getQueuePageOptions(r *http.Request, queuePageSizes map[string]PageSizes) (pageSize, pageNum int) {
+	return getPageOptionsWithSizes(r, queuePageSizes[mux.Vars(r)["qname"]])
+}
+
+func getPageOptionsWithSizes(r *http.Request, sizes PageSizes) (pageSize, pageNum int) {
+	pageSize = defaultPageSize
+	if sizes.Default > 0 {
+		pageSize = sizes.Default
+	}
+	pageNum = 1 // default page num
 	q := r.URL.Query()
 	if s := q.Get("size"); s != "" {
 		if n, err := strconv.Atoi(s); err == nil {
@@ -716,32 +1674,191 @@ func getPageOptions(r *http.Request) (pageSize, pageNum int) {
 			pageNum = n
 		}
 	}
+	if sizes.Max > 0 && pageSize > sizes.Max {
+		pageSize = sizes.Max
+	}
 	return pageSize, pageNum
 }
 
 func newGetTaskHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, rf ResultFormatter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
 		vars := mux.Vars(r)
 		qname, taskid := vars["qname"], vars["task_id"]
 		if qname == "" {
-			http.Error(w, "queue name cannot be empty", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "queue name cannot be empty")
 			return
 		}
 		if taskid == "" {
-			http.Error(w, "task_id cannot be empty", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "task_id cannot be empty")
+			return
+		}
+
+		info, err := inspector.GetTaskInfo(qname, taskid)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+
+		writeResponseJSON(w, toTaskInfo(info, pf, rf))
+	}
+}
+
+// newDownloadTaskPayloadHandlerFunc returns a handler which responds with the raw payload bytes of a task as a file.
+// With ?decode=base64, the payload is decoded from base64 before it is returned.
+// If redactor is non-nil, the configured fields are redacted from the payload.
+func newDownloadTaskPayloadHandlerFunc(inspector *asynq.Inspector, redactor *redactingPayloadFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if qname == "" || taskid == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
+			return
+		}
+		decode := r.URL.Query().Get("decode")
+		if decode != "" && decode != "base64" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for decode: %q", decode))
+			return
+		}
+
+		info, err := inspector.GetTaskInfo(qname, taskid)
+		switch {
+		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
+			return
+		case err != nil:
+			writeInternalServerError(w, r, err)
+			return
+		}
+
+		payload := info.Payload
+		if decode == "base64" {
+			if payload, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(payload))); err != nil {
+				respondError(w, http.StatusUnprocessableEntity, errCodeUnprocessable, fmt.Sprintf("payload is not valid base64: %v", err))
+				return
+			}
+		}
+		if redactor != nil {
+			payload = redactor.redact(info.Type, payload)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.ID+".bin"))
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		w.Write(payload)
+	}
+}
+
+type taskResultResponse struct {
+	ID    string `json:"id"`
+	Queue string `json:"queue"`
+	Type  string `json:"type"`
+	// Result bytes of the task; a string by default, or the JSON value with ?decode=json.
+	Result interface{} `json:"result"`
+	// Time the task was completed.
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// newGetTaskResultHandlerFunc returns a handler which returns the result of a completed task.
+// With ?decode=json, the result is decoded as JSON and returned as is, instead of as a string.
+func newGetTaskResultHandlerFunc(inspector *asynq.Inspector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		qname, taskid := vars["qname"], vars["task_id"]
+		if qname == "" || taskid == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "route parameters should not be empty")
+			return
+		}
+		decode := r.URL.Query().Get("decode")
+		if decode != "" && decode != "json" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for decode: %q", decode))
 			return
 		}
 
 		info, err := inspector.GetTaskInfo(qname, taskid)
 		switch {
 		case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
-			http.Error(w, strings.TrimPrefix(err.Error(), "asynq: "), http.StatusNotFound)
+			respondError(w, http.StatusNotFound, errCodeNotFound, strings.TrimPrefix(err.Error(), "asynq: "))
 			return
 		case err != nil:
-			http.Error(w, strings.TrimPrefix(err.Error(), "asynq: "), http.StatusInternalServerError)
+			writeInternalServerError(w, r, err)
 			return
 		}
+		if info.State != asynq.TaskStateCompleted {
+			respondError(w, http.StatusNotFound, errCodeNotFound, fmt.Sprintf("task is in %s state, not completed", info.State))
+			return
+		}
+		if len(info.Result) == 0 {
+			respondError(w, http.StatusNotFound, errCodeNotFound, "task has no result")
+			return
+		}
+
+		resp := taskResultResponse{
+			ID:          info.ID,
+			Queue:       info.Queue,
+			Type:        info.Type,
+			Result:      string(info.Result),
+			CompletedAt: info.CompletedAt,
+		}
+		if decode == "json" {
+			if !json.Valid(info.Result) {
+				respondError(w, http.StatusUnprocessableEntity, errCodeUnprocessable, "result is not valid JSON")
+				return
+			}
+			resp.Result = json.RawMessage(info.Result)
+		}
+		writeResponseJSON(w, resp)
+	}
+}
+
+type enqueueTaskRequest struct {
+	// Type name of the task.
+	Type string `json:"type"`
+	// Payload of the task. The JSON value is used as the payload bytes as is.
+	Payload json.RawMessage `json:"payload"`
+	// Optional time to process the task in RFC3339 format.
+	// If not set, the task is enqueued to be processed immediately.
+	ProcessAt string `json:"process_at"`
+}
 
+func newEnqueueTaskHandlerFunc(client *asynq.Client, pv PayloadValidator, pf PayloadFormatter, rf ResultFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qname := mux.Vars(r)["qname"]
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req enqueueTaskRequest
+		if err := dec.Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, err.Error())
+			return
+		}
+		if req.Type == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "task type cannot be empty")
+			return
+		}
+		opts := []asynq.Option{asynq.Queue(qname)}
+		if req.ProcessAt != "" {
+			t, err := time.Parse(time.RFC3339, req.ProcessAt)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for process_at: %q", req.ProcessAt))
+				return
+			}
+			opts = append(opts, asynq.ProcessAt(t))
+		}
+		if !validatePayload(w, pv, req.Type, req.Payload) {
+			return
+		}
+		info, err := client.Enqueue(asynq.NewTask(req.Type, req.Payload), opts...)
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
 		writeResponseJSON(w, toTaskInfo(info, pf, rf))
 	}
 }
@@ -0,0 +1,128 @@
+package asynqmon
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hibiken/asynq"
+)
+
+func TestTaskOptions(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc      string
+		info      *asynq.TaskInfo
+		processAt time.Time
+		want      []asynq.Option
+	}{
+		{
+			desc: "keeps deadline after process time",
+			info: &asynq.TaskInfo{
+				Queue:    "default",
+				MaxRetry: 5,
+				Timeout:  30 * time.Second,
+				Deadline: now.Add(time.Hour),
+			},
+			processAt: now,
+			want: []asynq.Option{
+				asynq.Queue("default"),
+				asynq.MaxRetry(5),
+				asynq.Timeout(30 * time.Second),
+				asynq.Deadline(now.Add(time.Hour)),
+			},
+		},
+		{
+			desc: "drops deadline before process time",
+			info: &asynq.TaskInfo{
+				Queue:    "default",
+				MaxRetry: 5,
+				Deadline: now.Add(-time.Hour),
+			},
+			processAt: now,
+			want: []asynq.Option{
+				asynq.Queue("default"),
+				asynq.MaxRetry(5),
+			},
+		},
+		{
+			desc: "drops deadline before rescheduled process time",
+			info: &asynq.TaskInfo{
+				Queue:     "critical",
+				MaxRetry:  3,
+				Deadline:  now.Add(time.Hour),
+				Retention: 24 * time.Hour,
+			},
+			processAt: now.Add(2 * time.Hour),
+			want: []asynq.Option{
+				asynq.Queue("critical"),
+				asynq.MaxRetry(3),
+				asynq.Retention(24 * time.Hour),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got := taskOptions(tc.info, tc.processAt)
+		if diff := cmp.Diff(optionStrings(tc.want), optionStrings(got)); diff != "" {
+			t.Errorf("%s: taskOptions mismatch (-want,+got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+// fakeListTasks returns a listTasksFunc which pages through n tasks, counting the pages listed.
+func fakeListTasks(n int, pages *int) listTasksFunc {
+	return func(qname string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+		*pages++
+		start, end := (*pages-1)*100, *pages*100
+		if end > n {
+			end = n
+		}
+		var tasks []*asynq.TaskInfo
+		for i := start; i < end; i++ {
+			tasks = append(tasks, &asynq.TaskInfo{ID: fmt.Sprintf("task%d", i), Queue: qname})
+		}
+		return tasks, nil
+	}
+}
+
+func TestScanTasks(t *testing.T) {
+	tests := []struct {
+		desc          string
+		n             int
+		limit         int
+		stopAt        int // index of the task at which fn returns errStopScan; -1 to never stop
+		wantScanned   int
+		wantTruncated bool
+		wantPages     int
+	}{
+		{desc: "scans all tasks", n: 250, limit: 1000, stopAt: -1, wantScanned: 250, wantTruncated: false, wantPages: 3},
+		{desc: "stops at limit", n: 250, limit: 100, stopAt: -1, wantScanned: 100, wantTruncated: true, wantPages: 2},
+		{desc: "stops within a page at limit", n: 250, limit: 150, stopAt: -1, wantScanned: 150, wantTruncated: true, wantPages: 2},
+		{desc: "not truncated at exactly limit tasks", n: 200, limit: 200, stopAt: -1, wantScanned: 200, wantTruncated: false, wantPages: 3},
+		{desc: "stops on errStopScan", n: 250, limit: 1000, stopAt: 120, wantScanned: 121, wantTruncated: true, wantPages: 2},
+		{desc: "not truncated on errStopScan at the last task", n: 250, limit: 1000, stopAt: 249, wantScanned: 250, wantTruncated: false, wantPages: 3},
+	}
+
+	for _, tc := range tests {
+		pages, calls := 0, 0
+		scanned, truncated, err := scanTasks(fakeListTasks(tc.n, &pages), "default", tc.limit, func(*asynq.TaskInfo) error {
+			calls++
+			if calls-1 == tc.stopAt {
+				return errStopScan
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: scanTasks returned error: %v", tc.desc, err)
+		}
+		if scanned != tc.wantScanned || truncated != tc.wantTruncated || pages != tc.wantPages {
+			t.Errorf("%s: scanTasks = (scanned %d, truncated %t) after %d pages, want (scanned %d, truncated %t) after %d pages",
+				tc.desc, scanned, truncated, pages, tc.wantScanned, tc.wantTruncated, tc.wantPages)
+		}
+		if calls != tc.wantScanned {
+			t.Errorf("%s: fn called %d times, want %d", tc.desc, calls, tc.wantScanned)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package asynqmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - http.Handler(s) for task search endpoints
+// ****************************************************************************
+
+// Default and maximum number of tasks scanned by a single search request.
+const (
+	defaultTaskSearchScan = 1000
+	maxTaskSearchScan     = 10000
+)
+
+// Maximum number of matching tasks returned by a single search request.
+const maxTaskSearchResults = 100
+
+type searchTasksResponse struct {
+	// Tasks whose payload matched the query, in the order they were scanned.
+	Tasks []*taskInfo `json:"tasks"`
+	// Number of tasks scanned.
+	Scanned int `json:"scanned"`
+	// Truncated indicates that the search stopped before reaching the end of the queue,
+	// either because the scan limit or the maximum number of results was reached.
+	// Tasks beyond that point were not searched.
+	Truncated bool `json:"truncated"`
+}
+
+// listTasksForState returns the function to list tasks in the given state, or false if the state is unknown.
+// Aggregating tasks are not supported since they are listed per group.
+func listTasksForState(inspector *tracedInspector, state string) (listTasksFunc, bool) {
+	switch state {
+	case "active":
+		return inspector.ListActiveTasks, true
+	case "pending":
+		return inspector.ListPendingTasks, true
+	case "scheduled":
+		return inspector.ListScheduledTasks, true
+	case "retry":
+		return inspector.ListRetryTasks, true
+	case "archived":
+		return inspector.ListArchivedTasks, true
+	case "completed":
+		return inspector.ListCompletedTasks, true
+	}
+	return nil, false
+}
+
+// newSearchTasksHandlerFunc returns a handler which searches the tasks in a queue by payload content.
+//
+// The search is best-effort: only the first tasks of the state are scanned,
+// and the response reports how many tasks were scanned and whether the scan was truncated.
+// Payloads are matched after redaction, so redacted values cannot be searched for.
+//
+// Query params:
+// `q`: string to search for (required)
+// `path`: dot-separated path of a JSON payload field (e.g. "user.id"); if set, only tasks whose
+// field value equals q match, otherwise tasks whose payload contains q as a substring match
+// `limit`: maximum number of tasks to scan (default 1000, max 10000)
+func newSearchTasksHandlerFunc(inspector *asynq.Inspector, pf PayloadFormatter, rf ResultFormatter, redactor *redactingPayloadFormatter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector := traceInspector(r.Context(), inspector)
+		vars := mux.Vars(r)
+		list, ok := listTasksForState(inspector, vars["state"])
+		if !ok {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("cannot search tasks in %s state", vars["state"]))
+			return
+		}
+		q := r.URL.Query()
+		query := q.Get("q")
+		if query == "" {
+			respondError(w, http.StatusBadRequest, errCodeInvalidArgument, "query param q is required")
+			return
+		}
+		var path []string
+		if s := q.Get("path"); s != "" {
+			path = strings.Split(s, ".")
+		}
+		limit := defaultTaskSearchScan
+		if s := q.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				respondError(w, http.StatusBadRequest, errCodeInvalidArgument, fmt.Sprintf("invalid value provided for limit: %q", s))
+				return
+			}
+			limit = n
+		}
+		if limit > maxTaskSearchScan {
+			limit = maxTaskSearchScan
+		}
+
+		resp := searchTasksResponse{Tasks: make([]*taskInfo, 0)}
+		scanned, truncated, err := scanTasks(list, vars["qname"], limit, func(t *asynq.TaskInfo) error {
+			payload := t.Payload
+			if redactor != nil {
+				payload = redactor.redact(t.Type, payload)
+			}
+			if matchPayload(payload, query, path) {
+				resp.Tasks = append(resp.Tasks, toTaskInfo(t, pf, rf))
+				if len(resp.Tasks) >= maxTaskSearchResults {
+					return errStopScan
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			writeInternalServerError(w, r, err)
+			return
+		}
+		resp.Scanned = scanned
+		resp.Truncated = truncated
+		writeResponseJSON(w, resp)
+	}
+}
+
+// matchPayload reports whether payload matches the query.
+// If path is empty, payload matches if it contains query.
+// Otherwise payload must be JSON, and the value found at path must equal query.
+func matchPayload(payload []byte, query string, path []string) bool {
+	if len(path) == 0 {
+		return bytes.Contains(payload, []byte(query))
+	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber() // compare numbers as written
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return false
+	}
+	return matchPath(v, path, query)
+}
+
+// matchPath reports whether the value found at path in v equals query.
+// If an array is found along the path, the rest of the path is applied to each element.
+func matchPath(v interface{}, path []string, query string) bool {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		child, ok := x[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			return jsonValueString(child) == query
+		}
+		return matchPath(child, path[1:], query)
+	case []interface{}:
+		for _, elem := range x {
+			if matchPath(elem, path, query) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonValueString returns strings as is and other values in their JSON encoding.
+func jsonValueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
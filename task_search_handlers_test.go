@@ -0,0 +1,74 @@
+package asynqmon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPayload(t *testing.T) {
+	tests := []struct {
+		desc    string
+		payload string
+		query   string
+		path    string
+		want    bool
+	}{
+		{
+			desc:    "Substring match",
+			payload: `{"user_id":"u-123","amount":10}`,
+			query:   "u-12",
+			want:    true,
+		},
+		{
+			desc:    "Substring no match",
+			payload: `{"user_id":"u-123"}`,
+			query:   "u-999",
+			want:    false,
+		},
+		{
+			desc:    "Nested string field",
+			payload: `{"user":{"id":"u-123"}}`,
+			query:   "u-123",
+			path:    "user.id",
+			want:    true,
+		},
+		{
+			desc:    "Path requires exact value",
+			payload: `{"user":{"id":"u-123"}}`,
+			query:   "u-12",
+			path:    "user.id",
+			want:    false,
+		},
+		{
+			desc:    "Number field",
+			payload: `{"user":{"id":42}}`,
+			query:   "42",
+			path:    "user.id",
+			want:    true,
+		},
+		{
+			desc:    "Field in array elements",
+			payload: `{"items":[{"sku":"a"},{"sku":"b"}]}`,
+			query:   "b",
+			path:    "items.sku",
+			want:    true,
+		},
+		{
+			desc:    "Non-JSON payload with path",
+			payload: `u-123`,
+			query:   "u-123",
+			path:    "user.id",
+			want:    false,
+		},
+	}
+
+	for _, tc := range tests {
+		var path []string
+		if tc.path != "" {
+			path = strings.Split(tc.path, ".")
+		}
+		if got := matchPayload([]byte(tc.payload), tc.query, path); got != tc.want {
+			t.Errorf("%s: matchPayload(%q, %q, %q) = %t, want %t", tc.desc, tc.payload, tc.query, tc.path, got, tc.want)
+		}
+	}
+}
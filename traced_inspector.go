@@ -0,0 +1,249 @@
+package asynqmon
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - inspector wrapper which traces each operation as a child span of a request
+// ****************************************************************************
+
+// tracedInspector calls the methods of an asynq.Inspector in an inspector span
+// (see startInspectorSpan) which is a child of the span in ctx.
+//
+// Handlers shadow their inspector with one per request, so that each call is a single line
+// and cannot skip tracing; the inspector is not embedded so that methods which are not
+// wrapped here cannot be called by accident.
+type tracedInspector struct {
+	ctx       context.Context
+	inspector *asynq.Inspector
+}
+
+// traceInspector returns an inspector which traces its operations as children of the span in ctx.
+func traceInspector(ctx context.Context, inspector *asynq.Inspector) *tracedInspector {
+	return &tracedInspector{ctx: ctx, inspector: inspector}
+}
+
+func (i *tracedInspector) Queues() ([]string, error) {
+	span := startInspectorSpan(i.ctx, "Queues")
+	v, err := i.inspector.Queues()
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) Groups(queue string) ([]*asynq.GroupInfo, error) {
+	span := startInspectorSpan(i.ctx, "Groups")
+	v, err := i.inspector.Groups(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
+	span := startInspectorSpan(i.ctx, "GetQueueInfo")
+	v, err := i.inspector.GetQueueInfo(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) History(queue string, n int) ([]*asynq.DailyStats, error) {
+	span := startInspectorSpan(i.ctx, "History")
+	v, err := i.inspector.History(queue, n)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteQueue(queue string, force bool) error {
+	span := startInspectorSpan(i.ctx, "DeleteQueue")
+	return endInspectorSpan(span, i.inspector.DeleteQueue(queue, force))
+}
+
+func (i *tracedInspector) GetTaskInfo(queue, id string) (*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "GetTaskInfo")
+	v, err := i.inspector.GetTaskInfo(queue, id)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListPendingTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListPendingTasks")
+	v, err := i.inspector.ListPendingTasks(queue, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListActiveTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListActiveTasks")
+	v, err := i.inspector.ListActiveTasks(queue, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListAggregatingTasks(queue, group string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListAggregatingTasks")
+	v, err := i.inspector.ListAggregatingTasks(queue, group, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListScheduledTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListScheduledTasks")
+	v, err := i.inspector.ListScheduledTasks(queue, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListRetryTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListRetryTasks")
+	v, err := i.inspector.ListRetryTasks(queue, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListArchivedTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListArchivedTasks")
+	v, err := i.inspector.ListArchivedTasks(queue, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListCompletedTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	span := startInspectorSpan(i.ctx, "ListCompletedTasks")
+	v, err := i.inspector.ListCompletedTasks(queue, opts...)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteAllPendingTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "DeleteAllPendingTasks")
+	v, err := i.inspector.DeleteAllPendingTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteAllScheduledTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "DeleteAllScheduledTasks")
+	v, err := i.inspector.DeleteAllScheduledTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteAllRetryTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "DeleteAllRetryTasks")
+	v, err := i.inspector.DeleteAllRetryTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteAllArchivedTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "DeleteAllArchivedTasks")
+	v, err := i.inspector.DeleteAllArchivedTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteAllCompletedTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "DeleteAllCompletedTasks")
+	v, err := i.inspector.DeleteAllCompletedTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteAllAggregatingTasks(queue, group string) (int, error) {
+	span := startInspectorSpan(i.ctx, "DeleteAllAggregatingTasks")
+	v, err := i.inspector.DeleteAllAggregatingTasks(queue, group)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) DeleteTask(queue, id string) error {
+	span := startInspectorSpan(i.ctx, "DeleteTask")
+	return endInspectorSpan(span, i.inspector.DeleteTask(queue, id))
+}
+
+func (i *tracedInspector) RunAllScheduledTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "RunAllScheduledTasks")
+	v, err := i.inspector.RunAllScheduledTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) RunAllRetryTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "RunAllRetryTasks")
+	v, err := i.inspector.RunAllRetryTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) RunAllArchivedTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "RunAllArchivedTasks")
+	v, err := i.inspector.RunAllArchivedTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) RunAllAggregatingTasks(queue, group string) (int, error) {
+	span := startInspectorSpan(i.ctx, "RunAllAggregatingTasks")
+	v, err := i.inspector.RunAllAggregatingTasks(queue, group)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) RunTask(queue, id string) error {
+	span := startInspectorSpan(i.ctx, "RunTask")
+	return endInspectorSpan(span, i.inspector.RunTask(queue, id))
+}
+
+func (i *tracedInspector) ArchiveAllPendingTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "ArchiveAllPendingTasks")
+	v, err := i.inspector.ArchiveAllPendingTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ArchiveAllScheduledTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "ArchiveAllScheduledTasks")
+	v, err := i.inspector.ArchiveAllScheduledTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ArchiveAllRetryTasks(queue string) (int, error) {
+	span := startInspectorSpan(i.ctx, "ArchiveAllRetryTasks")
+	v, err := i.inspector.ArchiveAllRetryTasks(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ArchiveAllAggregatingTasks(queue, group string) (int, error) {
+	span := startInspectorSpan(i.ctx, "ArchiveAllAggregatingTasks")
+	v, err := i.inspector.ArchiveAllAggregatingTasks(queue, group)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ArchiveTask(queue, id string) error {
+	span := startInspectorSpan(i.ctx, "ArchiveTask")
+	return endInspectorSpan(span, i.inspector.ArchiveTask(queue, id))
+}
+
+func (i *tracedInspector) CancelProcessing(id string) error {
+	span := startInspectorSpan(i.ctx, "CancelProcessing")
+	return endInspectorSpan(span, i.inspector.CancelProcessing(id))
+}
+
+func (i *tracedInspector) PauseQueue(queue string) error {
+	span := startInspectorSpan(i.ctx, "PauseQueue")
+	return endInspectorSpan(span, i.inspector.PauseQueue(queue))
+}
+
+func (i *tracedInspector) UnpauseQueue(queue string) error {
+	span := startInspectorSpan(i.ctx, "UnpauseQueue")
+	return endInspectorSpan(span, i.inspector.UnpauseQueue(queue))
+}
+
+func (i *tracedInspector) Servers() ([]*asynq.ServerInfo, error) {
+	span := startInspectorSpan(i.ctx, "Servers")
+	v, err := i.inspector.Servers()
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ClusterKeySlot(queue string) (int64, error) {
+	span := startInspectorSpan(i.ctx, "ClusterKeySlot")
+	v, err := i.inspector.ClusterKeySlot(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ClusterNodes(queue string) ([]*asynq.ClusterNode, error) {
+	span := startInspectorSpan(i.ctx, "ClusterNodes")
+	v, err := i.inspector.ClusterNodes(queue)
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) SchedulerEntries() ([]*asynq.SchedulerEntry, error) {
+	span := startInspectorSpan(i.ctx, "SchedulerEntries")
+	v, err := i.inspector.SchedulerEntries()
+	return v, endInspectorSpan(span, err)
+}
+
+func (i *tracedInspector) ListSchedulerEnqueueEvents(entryID string, opts ...asynq.ListOption) ([]*asynq.SchedulerEnqueueEvent, error) {
+	span := startInspectorSpan(i.ctx, "ListSchedulerEnqueueEvents")
+	v, err := i.inspector.ListSchedulerEnqueueEvents(entryID, opts...)
+	return v, endInspectorSpan(span, err)
+}
@@ -0,0 +1,146 @@
+package asynqmon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - middleware, redis hook and inspector helpers to trace API requests with OpenTelemetry
+// ****************************************************************************
+
+// tracerName is the name of the tracer used to create spans.
+const tracerName = "github.com/hibiken/asynqmon"
+
+// newTracingMiddleware returns a middleware which creates a span for each request, continuing
+// the trace propagated in the W3C Trace Context headers of the request if any.
+// It must be used on a mux.Router so that the matched route is available.
+func newTracingMiddleware(tp trace.TracerProvider) func(http.Handler) http.Handler {
+	tracer := tp.Tracer(tracerName)
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.URL.Path
+			if cr := mux.CurrentRoute(r); cr != nil {
+				if tmpl, err := cr.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", r.Method, route),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.method", r.Method),
+					attribute.String("http.route", route),
+					attribute.String("http.target", r.URL.RequestURI()),
+				))
+			defer span.End()
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.status_code", rec.status))
+			if rec.status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(rec.status))
+			}
+		})
+	}
+}
+
+// redisTracingHook is a redis.Hook which creates a span for each redis command
+// run with a context which belongs to a trace.
+type redisTracingHook struct {
+	tracer trace.Tracer
+}
+
+func newRedisTracingHook(tp trace.TracerProvider) *redisTracingHook {
+	return &redisTracingHook{tracer: tp.Tracer(tracerName)}
+}
+
+func (h *redisTracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.start(ctx, "redis "+cmd.FullName(), cmd.Name())
+}
+
+func (h *redisTracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.end(ctx, cmd.Err())
+	return nil
+}
+
+func (h *redisTracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	return h.start(ctx, "redis pipeline", strings.Join(names, " "))
+}
+
+func (h *redisTracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.end(ctx, err)
+	return nil
+}
+
+func (h *redisTracingHook) start(ctx context.Context, name, op string) (context.Context, error) {
+	// Do not create root spans for commands run outside of a request.
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, _ = h.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", op),
+		))
+	return ctx, nil
+}
+
+func (h *redisTracingHook) end(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startInspectorSpan starts a span for an inspector operation as a child of the span in ctx.
+// The inspector runs its redis commands without a context, so they cannot be traced by
+// redisTracingHook; the span covers the whole operation instead.
+// If ctx does not belong to a trace, a no-op span is returned.
+func startInspectorSpan(ctx context.Context, op string) trace.Span {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := parent.TracerProvider().Tracer(tracerName).Start(ctx, "asynq "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("asynq.operation", op)))
+	return span
+}
+
+// endInspectorSpan ends the span started by startInspectorSpan, recording err if non-nil.
+// It returns err so that it can wrap the result of the operation.
+func endInspectorSpan(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
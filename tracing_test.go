@@ -0,0 +1,86 @@
+package asynqmon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	router := mux.NewRouter()
+	router.Use(newTracingMiddleware(tp))
+	router.HandleFunc("/api/queues/{qname}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "redis is down", http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/api/queues/default", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if got, want := span.Name(), "GET /api/queues/{qname}"; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	if got, want := span.SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("trace id = %q, want %q propagated from traceparent header", got, want)
+	}
+	if got, want := span.Parent().SpanID().String(), "00f067aa0ba902b7"; got != want {
+		t.Errorf("parent span id = %q, want %q", got, want)
+	}
+	if got := span.Status().Code.String(); got != "Error" {
+		t.Errorf("span status = %q, want %q", got, "Error")
+	}
+}
+
+func TestInspectorSpans(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	// Nothing listens on the port, so the inspector call fails; the span is created regardless.
+	h := New(Options{
+		RedisConnOpt:   asynq.RedisClientOpt{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond},
+		TracerProvider: tp,
+	})
+	defer h.Close()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/queues/default", nil))
+
+	var server, child sdktrace.ReadOnlySpan
+	for _, span := range sr.Ended() {
+		switch span.Name() {
+		case "GET /api/queues/{qname}":
+			server = span
+		case "asynq GetQueueInfo":
+			child = span
+		}
+	}
+	if server == nil || child == nil {
+		t.Fatalf("got spans %v, want a server span and an inspector span", spanNames(sr.Ended()))
+	}
+	if got, want := child.Parent().SpanID(), server.SpanContext().SpanID(); got != want {
+		t.Errorf("inspector span parent = %v, want the server span %v", got, want)
+	}
+	if got := child.Status().Code.String(); got != "Error" {
+		t.Errorf("inspector span status = %q, want %q", got, "Error")
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	return names
+}
@@ -1,21 +1,40 @@
 import { AxiosError } from "axios";
 
+// ErrorResponse is the body of error responses from the API server.
+interface ErrorResponse {
+  error: { code: string; message: string; details?: string[] };
+}
+
+// errorMessage returns the error message from the response body.
+function errorMessage(data: ErrorResponse | string): string {
+  if (typeof data === "string") {
+    return data;
+  }
+  return data?.error?.message ?? JSON.stringify(data);
+}
+
 // toErrorStringWithHttpStatus returns a string representaion of axios error with HTTP status.
-export function toErrorStringWithHttpStatus(error: AxiosError<string>): string {
+export function toErrorStringWithHttpStatus(
+  error: AxiosError<ErrorResponse | string>
+): string {
   const { response } = error;
   if (!response) {
     return "error: no error response data available";
   }
-  return `${response.status} (${response.statusText}): ${response.data}`;
+  return `${response.status} (${response.statusText}): ${errorMessage(
+    response.data
+  )}`;
 }
 
 // toErrorString returns a string representaion of axios error.
-export function toErrorString(error: AxiosError<string>): string {
+export function toErrorString(
+  error: AxiosError<ErrorResponse | string>
+): string {
   const { response } = error;
   if (!response) {
     return "Unknown error occurred. See the logs for details.";
   }
-  return response.data;
+  return errorMessage(response.data);
 }
 
 interface Duration {
@@ -0,0 +1,60 @@
+package asynqmon
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ****************************************************************************
+// This file defines:
+//   - PayloadValidator used to validate payloads of tasks enqueued via the API
+// ****************************************************************************
+
+// PayloadValidator is used to validate payload bytes before a task is enqueued via the API.
+type PayloadValidator interface {
+	// ValidatePayload takes the task's typename and payload and returns a non-nil error if the payload is invalid.
+	// Return a *PayloadValidationError to report the details of the validation failure.
+	ValidatePayload(taskType string, payload []byte) error
+}
+
+// PayloadValidatorFunc is an adapter to allow the use of an ordinary function as a PayloadValidator.
+type PayloadValidatorFunc func(string, []byte) error
+
+// ValidatePayload calls f(taskType, payload).
+func (f PayloadValidatorFunc) ValidatePayload(taskType string, payload []byte) error {
+	return f(taskType, payload)
+}
+
+// PayloadValidationError describes why a payload is invalid for the task type.
+type PayloadValidationError struct {
+	// TaskType is the type name of the task.
+	TaskType string
+	// Errors is a list of messages describing each validation failure.
+	Errors []string
+}
+
+func (e *PayloadValidationError) Error() string {
+	return fmt.Sprintf("invalid payload for task type %q: %s", e.TaskType, strings.Join(e.Errors, "; "))
+}
+
+// validatePayload validates the payload using pv if pv is non-nil.
+// It writes 422 Unprocessable Entity response and returns false if the payload is invalid.
+// The validation errors are listed in the details of the error response.
+func validatePayload(w http.ResponseWriter, pv PayloadValidator, taskType string, payload []byte) bool {
+	if pv == nil {
+		return true
+	}
+	err := pv.ValidatePayload(taskType, payload)
+	if err == nil {
+		return true
+	}
+	detail := errorDetail{Code: errCodeInvalidPayload, Message: err.Error()}
+	var verr *PayloadValidationError
+	if errors.As(err, &verr) {
+		detail.Details = verr.Errors
+	}
+	writeErrorResponse(w, http.StatusUnprocessableEntity, detail)
+	return false
+}